@@ -1,13 +1,20 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"io"
 	"log"
+	"os"
+	"runtime/debug"
 
 	"github.com/spf13/viper"
 	"golang.org/x/tools/go/analysis/singlechecker"
 
 	"github.com/kunwardeep/paralleltest/pkg/paralleltest"
+	"github.com/kunwardeep/paralleltest/pkg/resultcache"
 )
 
 func main() {
@@ -29,5 +36,35 @@ func main() {
 		log.Fatalf("failed to unmarshal config: %v", err)
 	}
 
-	singlechecker.Main(paralleltest.NewAnalyzer(cfg))
+	// The flags are part of the cache key already, the salt adds the rest of the config.
+	salt, err := json.Marshal(cfg)
+	if err != nil {
+		log.Fatalf("failed to marshal config: %v", err)
+	}
+
+	singlechecker.Main(resultcache.Wrap(paralleltest.NewAnalyzer(cfg), version, string(salt), viper.GetString("cacheDir")))
+}
+
+// version identifies the build of the linter for the result cache. Builds from
+// a module version use it, other builds hash their executable. The result
+// cache only calls it when the cache is on.
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
@@ -0,0 +1,225 @@
+package paralleltest
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // G501: the reference key is the MD5 the cache used
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// syntheticPackage writes the source of a test package with the given number
+// of tests. Each test calls a chain of helpers and runs table driven subtests.
+func syntheticPackage(tests int) string {
+	var src strings.Builder
+	src.WriteString("package synthetic\n\nimport \"testing\"\n\n")
+	src.WriteString("var cases = []struct{ name string; in int }{{\"one\", 1}, {\"two\", 2}, {\"three\", 3}}\n\n")
+	for i := range tests {
+		fmt.Fprintf(&src, "func setup%d(t *testing.T) {\n\tt.Helper()\n\tcheck%d(t, %d)\n}\n\n", i, i, i)
+		fmt.Fprintf(&src, "func check%d(t *testing.T, in int) {\n\tt.Helper()\n\tif in < 0 {\n\t\tt.Fatal(in)\n\t}\n}\n\n", i)
+		fmt.Fprintf(&src, "func Test%d(t *testing.T) {\n\tt.Parallel()\n\tsetup%d(t)\n", i, i)
+		fmt.Fprintf(&src, "\tfor _, tc := range cases {\n\t\tt.Run(tc.name, func(t *testing.T) {\n\t\t\tt.Parallel()\n\t\t\tcheck%d(t, tc.in)\n\t\t})\n\t}\n}\n\n", i)
+	}
+	return src.String()
+}
+
+// syntheticPass type checks a synthetic package and returns a pass over it.
+// The result of inspect.Analyzer is left to the benchmark, which builds it as
+// part of each run.
+func syntheticPass(b *testing.B, tests int) *analysis.Pass {
+	b.Helper()
+
+	fset := token.NewFileSet()
+	name := b.TempDir() + "/synthetic_test.go"
+	src := syntheticPackage(tests)
+	if err := os.WriteFile(name, []byte(src), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		b.Fatal(err)
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Instances:  make(map[*ast.Ident]types.Instance),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("synthetic", fset, []*ast.File{file}, info)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return &analysis.Pass{
+		Fset:              fset,
+		Files:             []*ast.File{file},
+		Pkg:               pkg,
+		TypesInfo:         info,
+		TypesSizes:        types.SizesFor("gc", "amd64"),
+		ResultOf:          make(map[*analysis.Analyzer]any),
+		Report:            func(analysis.Diagnostic) {},
+		ReadFile:          os.ReadFile,
+		ImportObjectFact:  func(types.Object, analysis.Fact) bool { return false },
+		ExportObjectFact:  func(types.Object, analysis.Fact) {},
+		ImportPackageFact: func(*types.Package, analysis.Fact) bool { return false },
+		ExportPackageFact: func(analysis.Fact) {},
+	}
+}
+
+func BenchmarkAnalyzer(b *testing.B) {
+	for _, tests := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("tests=%d", tests), func(b *testing.B) {
+			pass := syntheticPass(b, tests)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for range b.N {
+				pass.ResultOf[inspect.Analyzer] = inspector.New(pass.Files)
+				analyzer := NewAnalyzer(Config{})
+				if _, err := analyzer.Run(pass); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// funcBodies returns the bodies of the function declarations of the pass.
+func funcBodies(pass *analysis.Pass) []*ast.BlockStmt {
+	var bodies []*ast.BlockStmt
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Body != nil {
+				bodies = append(bodies, funcDecl.Body)
+			}
+		}
+	}
+	return bodies
+}
+
+// printedHash is the key the analyses were cached under before they were
+// cached by body: the MD5 of the position and the gofmt-ed body.
+func printedHash(node ast.Node) string {
+	buf := bytes.Buffer{}
+	buf.WriteString(strconv.Itoa(int(node.Pos())))
+	gofmtConfig := &printer.Config{Tabwidth: 8}
+	_ = gofmtConfig.Fprint(&buf, token.NewFileSet(), node)
+	//nolint:gosec // G401: the reference key is the MD5 the cache used
+	sum := md5.Sum(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// BenchmarkAnalysisCache looks up the cached analysis of every function of a
+// synthetic package, keyed by body and by the printed hash of the body.
+func BenchmarkAnalysisCache(b *testing.B) {
+	pass := syntheticPass(b, 1000)
+	bodies := funcBodies(pass)
+
+	b.Run("key=body", func(b *testing.B) {
+		visited := make(map[*ast.BlockStmt]*testAnalysis, len(bodies))
+		for _, body := range bodies {
+			visited[body] = &testAnalysis{}
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for range b.N {
+			for _, body := range bodies {
+				if visited[body] == nil {
+					b.Fatal("missing analysis")
+				}
+			}
+		}
+	})
+
+	b.Run("key=hash", func(b *testing.B) {
+		visited := make(map[string]*testAnalysis, len(bodies))
+		for _, body := range bodies {
+			visited[printedHash(body)] = &testAnalysis{}
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for range b.N {
+			for _, body := range bodies {
+				if visited[printedHash(body)] == nil {
+					b.Fatal("missing analysis")
+				}
+			}
+		}
+	})
+}
+
+// scanFunction is how declarations were found before they were indexed: by
+// scanning every declaration of every file.
+func scanFunction(pass *analysis.Pass, fn *types.Func) *ast.FuncDecl {
+	fn = fn.Origin()
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok && pass.TypesInfo.Defs[funcDecl.Name] == fn {
+				return funcDecl
+			}
+		}
+	}
+	return nil
+}
+
+// BenchmarkFindFunction finds the declaration of every function of synthetic
+// packages, with the index of the pass built once per run and by scanning.
+func BenchmarkFindFunction(b *testing.B) {
+	for _, tests := range []int{100, 1000, 5000} {
+		pass := syntheticPass(b, tests)
+		var funcs []*types.Func
+		for _, file := range pass.Files {
+			for _, decl := range file.Decls {
+				if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+					funcs = append(funcs, pass.TypesInfo.Defs[funcDecl.Name].(*types.Func))
+				}
+			}
+		}
+
+		b.Run(fmt.Sprintf("tests=%d/index", tests), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				// The declarations are indexed as runPass does.
+				a := &parallelAnalyzer{decls: make(map[*types.Func]*ast.FuncDecl)}
+				inspector.New(pass.Files).Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(node ast.Node) {
+					funcDecl := node.(*ast.FuncDecl)
+					a.decls[pass.TypesInfo.Defs[funcDecl.Name].(*types.Func)] = funcDecl
+				})
+				for _, fn := range funcs {
+					if a.findFunction(fn) == nil {
+						b.Fatal("missing declaration")
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("tests=%d/scan", tests), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				for _, fn := range funcs {
+					if scanFunction(pass, fn) == nil {
+						b.Fatal("missing declaration")
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package paralleltest
+
+import (
+	"fmt"
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// isSynctestTest reports whether the call is to synctest.Test, which runs a
+// function in a bubble with a T of its own.
+func isSynctestTest(pass *analysis.Pass, callExpr *ast.CallExpr) bool {
+	fn := calleeFunc(pass, callExpr)
+	return fn != nil && fn.FullName() == "testing/synctest.Test"
+}
+
+// analyzeBubble analyzes the function that a call to synctest.Test runs in a
+// bubble, a literal or a function of the package, and reports its calls to
+// t.Parallel, t.Run and t.Deadline on the T of the bubble, which panic. The
+// function is not a test of its own: it isn't asked to call t.Parallel.
+func (a *parallelAnalyzer) analyzeBubble(pass *analysis.Pass, callExpr *ast.CallExpr) *testAnalysis {
+	var name string
+	var funcType *ast.FuncType
+	var body *ast.BlockStmt
+	if funcLit, ok := callExpr.Args[1].(*ast.FuncLit); ok {
+		name, funcType, body = "literal", funcLit.Type, funcLit.Body
+	} else if fn := referencedFunc(pass, callExpr.Args[1]); fn != nil {
+		if funcDecl := a.findFunction(fn); funcDecl != nil {
+			name, funcType, body = fn.Name(), funcDecl.Type, funcDecl.Body
+		}
+	}
+	if body == nil {
+		return &testAnalysis{}
+	}
+	testVar := findTestParam(pass, funcType.Params)
+	if testVar == nil {
+		return &testAnalysis{}
+	}
+	a.bubbles[testVar] = true
+	result := a.analyzeFunctionF(pass, funcType, body)
+	if !result.final() {
+		return result
+	}
+
+	report := func(node ast.Node, call string) {
+		pass.Report(analysis.Diagnostic{
+			Pos:     node.Pos(),
+			Message: fmt.Sprintf("Function %s calls %s in a synctest bubble, which panics: Parallel, Run and Deadline can't be called on the T of synctest.Test\n", name, call),
+		})
+	}
+	for _, call := range result.parallelCalls {
+		report(call.call, call.describe(testVar.Name()))
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		if callExpr, ok := n.(*ast.CallExpr); ok {
+			for _, method := range []string{"Run", "Deadline"} {
+				if exprCallHasMethod(pass, callExpr, testVar, method) {
+					report(callExpr, testVar.Name()+"."+method)
+				}
+			}
+		}
+		return true
+	})
+	return result
+}
@@ -0,0 +1,329 @@
+package paralleltest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// Engines decide how the calls a test reaches are found.
+const (
+	// EngineAST follows the calls of the syntax tree to the functions they name.
+	EngineAST = "ast"
+	// EngineCHA additionally follows an SSA call graph built with class
+	// hierarchy analysis, which resolves calls through interfaces to every
+	// method that implements them. Calls of function values are not followed,
+	// CHA would resolve them to every function with the same signature.
+	EngineCHA = "cha"
+	// EngineVTA additionally follows an SSA call graph built with variable
+	// type analysis, which resolves calls through interfaces and function
+	// values to the values that flow to them.
+	EngineVTA = "vta"
+)
+
+// validEngine returns an error if the engine is unknown.
+func validEngine(engine string) error {
+	switch engine {
+	case "", EngineAST, EngineCHA, EngineVTA:
+		return nil
+	default:
+		return fmt.Errorf("unknown engine %q, want %q, %q or %q", engine, EngineAST, EngineCHA, EngineVTA)
+	}
+}
+
+// callGraph finds the calls to t.Parallel, t.Setenv, t.Chdir and the extra
+// signatures that a function reaches through the SSA call graph of its package.
+// The graph is built on first use, only packages with tests or helpers need it.
+type callGraph struct {
+	pass      *analysis.Pass
+	engine    string
+	extraSigs []string
+
+	once  sync.Once
+	graph *callgraph.Graph
+	// funcs maps the body of each function and function literal to its SSA function.
+	funcs map[*ast.BlockStmt]*ssa.Function
+}
+
+// build builds the SSA form and the call graph of the package. It creates the
+// program the way buildssa does. The analyzer doesn't require buildssa itself:
+// the facts make it run on every dependency, and buildssa would build the SSA
+// form of each of them, the standard library included.
+func (g *callGraph) build() {
+	pass := g.pass
+	prog := ssa.NewProgram(pass.Fset, ssa.BuilderMode(0))
+	for _, p := range pass.Pkg.Imports() {
+		prog.CreatePackage(p, nil, nil, true)
+	}
+	ssaPkg := prog.CreatePackage(pass.Pkg, pass.Files, pass.TypesInfo, false)
+	ssaPkg.Build()
+
+	g.graph = cha.CallGraph(prog)
+	if g.engine == EngineVTA {
+		g.graph = vta.CallGraph(ssautil.AllFunctions(prog), g.graph)
+	}
+
+	g.funcs = make(map[*ast.BlockStmt]*ssa.Function)
+	var addFunc func(fn *ssa.Function)
+	addFunc = func(fn *ssa.Function) {
+		switch syntax := fn.Syntax().(type) {
+		case *ast.FuncDecl:
+			g.funcs[syntax.Body] = fn
+		case *ast.FuncLit:
+			g.funcs[syntax.Body] = fn
+		}
+		for _, anon := range fn.AnonFuncs {
+			addFunc(anon)
+		}
+	}
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+				if fn := prog.FuncValue(pass.TypesInfo.Defs[funcDecl.Name].(*types.Func)); fn != nil {
+					addFunc(fn)
+				}
+			}
+		}
+	}
+}
+
+// reach returns the analysis of the calls that the function with the given
+// body transitively reaches. Function literals that are only passed along,
+// such as the subtests of t.Run, are not reached. Only the calls to the
+// methods of the T of the function count: the T has to flow to the receiver
+// through the arguments of the calls and the variables captured by closures,
+// as itself or converted to an interface such as testing.TB. A T stored in a
+// struct field or obtained otherwise is not followed. The reason that prevents
+// t.Parallel names the shortest call chain found.
+func (g *callGraph) reach(body *ast.BlockStmt) *testAnalysis {
+	g.once.Do(g.build)
+	analysis := &testAnalysis{}
+	fn := g.funcs[body]
+	node := g.graph.Nodes[fn]
+	if node == nil {
+		return analysis
+	}
+	var root testVars
+	for i, param := range fn.Params {
+		if isTestingTB(g.pass, param.Type()) {
+			root |= 1 << i
+		}
+	}
+
+	start := reachState{node, root}
+	parent := map[reachState]reachState{start: {}}
+	// reached holds the union of the test variables of each function reached.
+	reached := map[*ssa.Function]testVars{fn: root}
+	chain := func(s reachState) string {
+		var names []string
+		for ; s != start; s = parent[s] {
+			names = append(names, s.node.Func.RelString(g.pass.Pkg))
+		}
+		for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+			names[i], names[j] = names[j], names[i]
+		}
+		return "calls " + strings.Join(names, " → ")
+	}
+
+	queue := []reachState{start}
+	for len(queue) > 0 && !(analysis.hasParallel && analysis.cantParallel) {
+		caller := queue[0]
+		queue = queue[1:]
+		for _, edge := range caller.node.Out {
+			if edge.Site == nil {
+				continue
+			}
+			if g.engine == EngineCHA && isFuncValueCall(edge) && !makesClosure(caller.node.Func, edge.Callee.Func) {
+				// CHA links a call of a function value to every function of
+				// the program with the same signature, such as every other test.
+				// Only the closures and method values the caller creates are
+				// followed.
+				continue
+			}
+			args := callArgs(edge.Site.Common())
+			callee := reachState{edge.Callee, calleeVars(caller, edge.Callee.Func, args, reached)}
+			if _, ok := parent[callee]; ok {
+				continue
+			}
+			parent[callee] = caller
+			reached[callee.node.Func] |= callee.vars
+
+			// The T is the receiver, the only test variable of the methods.
+			receivesT := callee.vars != 0
+			switch {
+			case isTestingMethod(callee.node.Func, "Parallel"):
+				analysis.hasParallel = analysis.hasParallel || receivesT
+			case isTestingMethod(callee.node.Func, "Setenv"), isTestingMethod(callee.node.Func, "Chdir"):
+				if receivesT {
+					analysis.markCantParallel(chain(callee))
+				}
+			case g.isExtraSig(callee.node.Func):
+				analysis.markCantParallel(chain(callee))
+			default:
+				queue = append(queue, callee)
+			}
+		}
+	}
+
+	return analysis
+}
+
+// testVars is the set of the parameters, followed by the free variables, of a
+// function that hold the T of the test.
+type testVars uint64
+
+// reachState is a function reached with the given test variables.
+type reachState struct {
+	node *callgraph.Node
+	vars testVars
+}
+
+// isTestVar reports whether the value of the function of the state is the T of
+// the test, as itself or converted to another type.
+func (s reachState) isTestVar(v ssa.Value) bool {
+	return s.vars.has(s.node.Func, v)
+}
+
+// has reports whether the value is one of the test variables of the function,
+// as itself or converted to another type.
+func (vars testVars) has(fn *ssa.Function, v ssa.Value) bool {
+	v = unconvert(v)
+	for i, param := range fn.Params {
+		if param == v {
+			return i < 64 && vars&(1<<i) != 0
+		}
+	}
+	for i, freeVar := range fn.FreeVars {
+		if freeVar == v {
+			i += numParams(fn)
+			return i < 64 && vars&(1<<i) != 0
+		}
+	}
+	return false
+}
+
+// unconvert returns the value that v was converted from, through any number
+// of conversions, or v itself.
+func unconvert(v ssa.Value) ssa.Value {
+	for {
+		switch x := v.(type) {
+		case *ssa.ChangeType:
+			v = x.X
+		case *ssa.MakeInterface:
+			v = x.X
+		case *ssa.ChangeInterface:
+			v = x.X
+		case *ssa.TypeAssert:
+			v = x.X
+		default:
+			return v
+		}
+	}
+}
+
+// callArgs returns the arguments of a call, starting with the receiver of
+// the method called, in the order of the parameters of the callee.
+func callArgs(common *ssa.CallCommon) []ssa.Value {
+	if common.IsInvoke() {
+		return append([]ssa.Value{common.Value}, common.Args...)
+	}
+	return common.Args
+}
+
+// calleeVars returns the test variables of a function called with the given
+// arguments: the parameters that receive a test variable of the caller, and
+// the free variables bound to one when the function is a closure.
+func calleeVars(caller reachState, callee *ssa.Function, args []ssa.Value, reached map[*ssa.Function]testVars) testVars {
+	var vars testVars
+	for i, arg := range args {
+		if i < 64 && caller.isTestVar(arg) {
+			vars |= 1 << i
+		}
+	}
+	if len(callee.FreeVars) == 0 {
+		return vars
+	}
+	// The closure is bound to its variables where it is created: by the
+	// caller, as for the method values such as t.Parallel, or by the function
+	// enclosing it, with the test variables that function was reached with.
+	creator, creatorVars := caller.node.Func, caller.vars
+	if !makesClosure(creator, callee) {
+		creator = callee.Parent()
+		if creator == nil {
+			return vars
+		}
+		creatorVars = reached[creator]
+	}
+	for _, block := range creator.Blocks {
+		for _, instr := range block.Instrs {
+			closure, ok := instr.(*ssa.MakeClosure)
+			if !ok || closure.Fn != callee {
+				continue
+			}
+			for j, binding := range closure.Bindings {
+				if i := numParams(callee) + j; i < 64 && creatorVars.has(creator, binding) {
+					vars |= 1 << i
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// numParams returns the number of parameters of the function, including the
+// receiver. Functions of other packages have a signature but no parameters.
+func numParams(fn *ssa.Function) int {
+	n := fn.Signature.Params().Len()
+	if fn.Signature.Recv() != nil {
+		n++
+	}
+	return n
+}
+
+// makesClosure reports whether the function creates a closure of the callee.
+func makesClosure(fn, callee *ssa.Function) bool {
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			if closure, ok := instr.(*ssa.MakeClosure); ok && closure.Fn == callee {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isExtraSig reports whether the function matches one of the extra signatures
+// that can't be used with t.Parallel.
+func (g *callGraph) isExtraSig(fn *ssa.Function) bool {
+	if obj := fn.Object(); obj != nil {
+		return contains(g.extraSigs, obj.String())
+	}
+	return false
+}
+
+// isTestingMethod reports whether the function is the method with the given
+// name of a type of the testing package, or a wrapper of one.
+func isTestingMethod(fn *ssa.Function, name string) bool {
+	obj, ok := fn.Object().(*types.Func)
+	return ok && obj.Name() == name && obj.Pkg() != nil && obj.Pkg().Path() == testMethodPackageType &&
+		obj.Type().(*types.Signature).Recv() != nil
+}
+
+// isFuncValueCall reports whether the edge is a call of a function value,
+// rather than a static call or a call of an interface method.
+func isFuncValueCall(edge *callgraph.Edge) bool {
+	if edge.Site == nil {
+		return false
+	}
+	common := edge.Site.Common()
+	return !common.IsInvoke() && common.StaticCallee() == nil
+}
@@ -0,0 +1,304 @@
+package paralleltest
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// funcValues returns the function literals and function references that may
+// flow into expr, when expr is a variable or a struct field. Variables are
+// followed through their declarations and assignments, and fields through the
+// composite literals that set them, as in table-driven tests:
+//
+//	run := func(t *testing.T) {...}
+//	t.Run("name", run)
+//
+//	for _, tc := range []struct{ name string; test func(*testing.T) }{...} {
+//		t.Run(tc.name, tc.test)
+//	}
+//
+//	for name, test := range map[string]func(*testing.T){...} {
+//		t.Run(name, test)
+//	}
+func funcValues(pass *analysis.Pass, expr ast.Expr) []ast.Expr {
+	return collectFuncValues(pass, expr, make(map[types.Object]bool))
+}
+
+func collectFuncValues(pass *analysis.Pass, expr ast.Expr, seen map[types.Object]bool) []ast.Expr {
+	var sources []ast.Expr
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.FuncLit:
+		return []ast.Expr{e}
+	case *ast.Ident:
+		if fn := referencedFunc(pass, e); fn != nil {
+			return []ast.Expr{e}
+		}
+		v, ok := pass.TypesInfo.Uses[e].(*types.Var)
+		if !ok || v.IsField() || seen[v] {
+			return nil
+		}
+		seen[v] = true
+		sources = assignedValues(pass, v)
+		for _, container := range rangeContainers(pass, v) {
+			elts, _ := elements(pass, container, seen)
+			sources = append(sources, elts...)
+		}
+	case *ast.SelectorExpr:
+		if fn := referencedFunc(pass, e); fn != nil {
+			return []ast.Expr{e}
+		}
+		selection, ok := pass.TypesInfo.Selections[e]
+		if !ok || selection.Kind() != types.FieldVal {
+			return nil
+		}
+		field := selection.Obj().(*types.Var).Origin()
+		if lits, ok := structLits(pass, e.X, seen); ok {
+			for _, lit := range lits {
+				sources = append(sources, litFieldValues(pass, lit, field)...)
+			}
+		} else if !seen[field] {
+			// The struct values can't be traced, fall back to every literal
+			// of the package that sets the field.
+			seen[field] = true
+			sources = fieldValues(pass, field)
+		}
+	case *ast.IndexExpr:
+		if fn := referencedFunc(pass, e); fn != nil {
+			return []ast.Expr{e}
+		}
+		sources, _ = elements(pass, e.X, seen)
+	case *ast.IndexListExpr:
+		if fn := referencedFunc(pass, e); fn != nil {
+			return []ast.Expr{e}
+		}
+	}
+
+	var values []ast.Expr
+	for _, source := range sources {
+		values = append(values, collectFuncValues(pass, source, seen)...)
+	}
+	return values
+}
+
+// assignedValues returns the expressions assigned to the variable in its
+// declaration and in later assignments within its scope.
+func assignedValues(pass *analysis.Pass, v *types.Var) []ast.Expr {
+	var values []ast.Expr
+	refersTo := func(expr ast.Expr) bool {
+		ident, ok := expr.(*ast.Ident)
+		return ok && pass.TypesInfo.ObjectOf(ident) == v
+	}
+
+	inspectScope(pass, v, func(n ast.Node) {
+		switch s := n.(type) {
+		case *ast.AssignStmt:
+			if len(s.Lhs) != len(s.Rhs) {
+				return
+			}
+			for i, lhs := range s.Lhs {
+				if refersTo(lhs) {
+					values = append(values, s.Rhs[i])
+				}
+			}
+		case *ast.ValueSpec:
+			if len(s.Names) != len(s.Values) {
+				return
+			}
+			for i, name := range s.Names {
+				if refersTo(name) {
+					values = append(values, s.Values[i])
+				}
+			}
+		}
+	})
+	return values
+}
+
+// structLits returns the struct composite literals that expr may evaluate to.
+// It follows variables, range loops and indexing into slice, array and map
+// literals, and reports false when some value can't be traced to a literal.
+func structLits(pass *analysis.Pass, expr ast.Expr, seen map[types.Object]bool) ([]*ast.CompositeLit, bool) {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.CompositeLit:
+		if _, ok := structType(pass, e); ok {
+			return []*ast.CompositeLit{e}, true
+		}
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return structLits(pass, e.X, seen)
+		}
+	case *ast.IndexExpr:
+		return elementLits(pass, e.X, seen)
+	case *ast.Ident:
+		v, ok := pass.TypesInfo.Uses[e].(*types.Var)
+		if !ok || v.IsField() || seen[v] {
+			return nil, false
+		}
+		seen[v] = true
+
+		var lits []*ast.CompositeLit
+		for _, value := range assignedValues(pass, v) {
+			valueLits, ok := structLits(pass, value, seen)
+			if !ok {
+				return nil, false
+			}
+			lits = append(lits, valueLits...)
+		}
+		for _, container := range rangeContainers(pass, v) {
+			containerLits, ok := elementLits(pass, container, seen)
+			if !ok {
+				return nil, false
+			}
+			lits = append(lits, containerLits...)
+		}
+		return lits, len(lits) > 0
+	}
+	return nil, false
+}
+
+// elementLits returns the struct composite literals that the elements of the
+// slice, array or map expression may evaluate to.
+func elementLits(pass *analysis.Pass, container ast.Expr, seen map[types.Object]bool) ([]*ast.CompositeLit, bool) {
+	elts, ok := elements(pass, container, seen)
+	if !ok {
+		return nil, false
+	}
+	var lits []*ast.CompositeLit
+	for _, elt := range elts {
+		eltLits, ok := structLits(pass, elt, seen)
+		if !ok {
+			return nil, false
+		}
+		lits = append(lits, eltLits...)
+	}
+	return lits, len(lits) > 0
+}
+
+// elements returns the elements of the slice, array or map literals that the
+// container expression may evaluate to, and reports false when some value
+// can't be traced to a literal.
+func elements(pass *analysis.Pass, container ast.Expr, seen map[types.Object]bool) ([]ast.Expr, bool) {
+	var containers []ast.Expr
+	switch c := ast.Unparen(container).(type) {
+	case *ast.CompositeLit:
+		containers = []ast.Expr{c}
+	case *ast.Ident:
+		v, ok := pass.TypesInfo.Uses[c].(*types.Var)
+		if !ok || v.IsField() || seen[v] {
+			return nil, false
+		}
+		seen[v] = true
+		containers = assignedValues(pass, v)
+	}
+
+	var elts []ast.Expr
+	for _, expr := range containers {
+		containerLit, ok := ast.Unparen(expr).(*ast.CompositeLit)
+		if !ok {
+			return nil, false
+		}
+		for _, elt := range containerLit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			elts = append(elts, elt)
+		}
+	}
+	return elts, len(containers) > 0
+}
+
+// rangeContainers returns the expressions ranged over by loops that declare
+// the variable as their value.
+func rangeContainers(pass *analysis.Pass, v *types.Var) []ast.Expr {
+	var containers []ast.Expr
+	inspectScope(pass, v, func(n ast.Node) {
+		if rangeStmt, ok := n.(*ast.RangeStmt); ok {
+			if value, ok := rangeStmt.Value.(*ast.Ident); ok && pass.TypesInfo.ObjectOf(value) == v {
+				containers = append(containers, rangeStmt.X)
+			}
+		}
+	})
+	return containers
+}
+
+// fieldValues returns the expressions that composite literals of the package
+// set the field to.
+func fieldValues(pass *analysis.Pass, field *types.Var) []ast.Expr {
+	var values []ast.Expr
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.CompositeLit); ok {
+				values = append(values, litFieldValues(pass, lit, field)...)
+			}
+			return true
+		})
+	}
+	return values
+}
+
+// litFieldValues returns the expression the struct literal sets the field to,
+// whether keyed or positional.
+func litFieldValues(pass *analysis.Pass, lit *ast.CompositeLit, field *types.Var) []ast.Expr {
+	st, ok := structType(pass, lit)
+	if !ok {
+		return nil
+	}
+	for i, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok && isSameField(pass.TypesInfo.ObjectOf(key), field) {
+				return []ast.Expr{kv.Value}
+			}
+		} else if i < st.NumFields() && isSameField(st.Field(i), field) {
+			return []ast.Expr{elt}
+		}
+	}
+	return nil
+}
+
+// structType returns the struct type of a composite literal.
+func structType(pass *analysis.Pass, lit *ast.CompositeLit) (*types.Struct, bool) {
+	typ := pass.TypesInfo.TypeOf(lit)
+	if typ == nil {
+		return nil, false
+	}
+	st, ok := deref(typ).Underlying().(*types.Struct)
+	return st, ok
+}
+
+func isSameField(obj types.Object, field *types.Var) bool {
+	v, ok := obj.(*types.Var)
+	return ok && v.IsField() && v.Origin() == field
+}
+
+// inspectScope calls f for each node within the scope the object is declared
+// in, or within every file for package-level objects.
+func inspectScope(pass *analysis.Pass, obj types.Object, f func(ast.Node)) {
+	scope := obj.Parent()
+	for _, file := range pass.Files {
+		from, to := file.Pos(), file.End()
+		if scope != nil && scope != pass.Pkg.Scope() {
+			if !(file.Pos() <= scope.Pos() && scope.End() <= file.End()) {
+				continue
+			}
+			from, to = scope.Pos(), scope.End()
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if n == nil || n.End() < from || n.Pos() > to {
+				return false
+			}
+			f(n)
+			return true
+		})
+	}
+}
+
+// deref returns the element type of a pointer type, or the type itself.
+func deref(typ types.Type) types.Type {
+	if ptr, ok := types.Unalias(typ).(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return typ
+}
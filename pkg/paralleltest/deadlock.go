@@ -0,0 +1,210 @@
+package paralleltest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// release is an operation of a parallel subtest on a sync primitive or a
+// channel shared with the function that started it.
+type release struct {
+	// pos is the position of the operation in the subtest.
+	pos token.Pos
+	// what describes the operation, such as "call wg.Done" or "send to ch".
+	what string
+	// subtest is the name of the subtest.
+	subtest string
+}
+
+// reportDeadlocks reports the operations of a test that block, after it
+// started parallel subtests, until the subtests release them: waiting on a
+// sync.WaitGroup they call Done on, receiving from a channel they send to or
+// close, or locking a mutex they unlock. The parallel subtests only run once
+// the test returns, so the test deadlocks. The operations in t.Cleanup
+// functions, goroutines and subtests don't block the test and are left out.
+func (a *parallelAnalyzer) reportDeadlocks(pass *analysis.Pass, result *testAnalysis, name string, testVar types.Object, body *ast.BlockStmt) {
+	parallel := a.parallelLiterals(result, body)
+	releases := make(map[types.Object]release)
+	for _, sub := range parallel {
+		for obj, r := range subtestReleases(pass, sub, a.visited[sub.body].parallelCall) {
+			if _, ok := releases[obj]; !ok {
+				releases[obj] = r
+			}
+		}
+	}
+	if len(releases) == 0 {
+		return
+	}
+
+	skip := make(map[*ast.BlockStmt]bool)
+	for _, sub := range result.subtests {
+		skip[sub.body] = true
+	}
+	report := func(node ast.Node, expr ast.Expr, block string) {
+		ident := baseIdent(expr)
+		if ident == nil {
+			return
+		}
+		r, ok := releases[pass.TypesInfo.Uses[ident]]
+		if !ok || !startedBefore(parallel, node.Pos()) {
+			return
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos: node.Pos(),
+			Message: fmt.Sprintf("Function %s %s, which waits for its parallel subtests to %s, but they only run once %s returns: the test deadlocks\n",
+				name, block, r.what, name),
+			Related: []analysis.RelatedInformation{{Pos: r.pos, Message: "released in the subtest " + r.subtest}},
+		})
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return !skip[n.Body]
+		case *ast.GoStmt:
+			if lit, ok := ast.Unparen(n.Call.Fun).(*ast.FuncLit); ok {
+				skip[lit.Body] = true
+			}
+		case *ast.SelectStmt:
+			// A select may have other cases or a default.
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				report(n, n.X, "receives from "+types.ExprString(n.X))
+			}
+		case *ast.RangeStmt:
+			if isChan(pass, n.X) {
+				report(n, n.X, "ranges over "+types.ExprString(n.X))
+			}
+		case *ast.CallExpr:
+			if exprCallHasMethod(pass, n, testVar, "Cleanup") {
+				for _, arg := range methodArgs(pass, n) {
+					if lit, ok := arg.(*ast.FuncLit); ok {
+						skip[lit.Body] = true
+					}
+				}
+			}
+			fn := calleeFunc(pass, n)
+			if fn == nil {
+				return true
+			}
+			switch fn.FullName() {
+			case "(*sync.WaitGroup).Wait", "(*sync.Mutex).Lock", "(*sync.RWMutex).Lock", "(*sync.RWMutex).RLock":
+				if receiver := methodReceiver(pass, n); receiver != nil {
+					report(n, receiver, "calls "+types.ExprString(receiver)+"."+fn.Name())
+				}
+			}
+		}
+		return true
+	})
+}
+
+// startedBefore reports whether one of the subtests was started before pos.
+func startedBefore(subtests []subtest, pos token.Pos) bool {
+	for _, sub := range subtests {
+		if sub.body.End() < pos {
+			return true
+		}
+	}
+	return false
+}
+
+// subtestReleases returns the variables declared outside the body of the
+// subtest that it releases once it is parallel: the sync.WaitGroups it calls
+// Done on, the channels it sends to or closes, and the mutexes it unlocks more
+// often than it locks. The operations before its call to t.Parallel run before
+// t.Run returns and don't count.
+func subtestReleases(pass *analysis.Pass, sub subtest, parallel *testCall) map[types.Object]release {
+	releases := make(map[types.Object]release)
+	late := afterParallel(sub.body, parallel)
+	locks := make(map[types.Object]int)
+	outer := func(expr ast.Expr) types.Object {
+		ident := baseIdent(expr)
+		if ident == nil {
+			return nil
+		}
+		v, ok := pass.TypesInfo.Uses[ident].(*types.Var)
+		if !ok || v.IsField() || (sub.body.Pos() <= v.Pos() && v.Pos() < sub.body.End()) {
+			return nil
+		}
+		return v
+	}
+	add := func(pos token.Pos, expr ast.Expr, what string) {
+		if obj := outer(expr); obj != nil && late(pos) {
+			if _, ok := releases[obj]; !ok {
+				releases[obj] = release{pos, what, sub.name}
+			}
+		}
+	}
+
+	ast.Inspect(sub.body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SendStmt:
+			add(n.Pos(), n.Chan, "send to "+types.ExprString(n.Chan))
+		case *ast.CallExpr:
+			if ident, ok := ast.Unparen(n.Fun).(*ast.Ident); ok && len(n.Args) == 1 {
+				if b, ok := pass.TypesInfo.Uses[ident].(*types.Builtin); ok && b.Name() == "close" {
+					add(n.Pos(), n.Args[0], "close "+types.ExprString(n.Args[0]))
+				}
+			}
+			fn := calleeFunc(pass, n)
+			receiver := methodReceiver(pass, n)
+			if fn == nil || receiver == nil {
+				return true
+			}
+			switch fn.FullName() {
+			case "(*sync.WaitGroup).Done":
+				add(n.Pos(), receiver, "call "+types.ExprString(receiver)+".Done")
+			case "(*sync.Mutex).Lock", "(*sync.RWMutex).Lock":
+				if obj := outer(receiver); obj != nil {
+					locks[obj]++
+				}
+			case "(*sync.Mutex).Unlock", "(*sync.RWMutex).Unlock":
+				if obj := outer(receiver); obj != nil {
+					if locks[obj]--; locks[obj] < 0 {
+						add(n.Pos(), receiver, "call "+types.ExprString(receiver)+".Unlock")
+					}
+				}
+			}
+		}
+		return true
+	})
+	return releases
+}
+
+// isChan reports whether the expression is a channel.
+func isChan(pass *analysis.Pass, expr ast.Expr) bool {
+	typ := pass.TypesInfo.TypeOf(expr)
+	if typ == nil {
+		return false
+	}
+	_, ok := typ.Underlying().(*types.Chan)
+	return ok
+}
+
+// afterParallel returns whether the code of a subtest body at a position runs
+// once the subtest is parallel: after its call to t.Parallel, or in a deferred
+// call, which runs when the subtest returns.
+func afterParallel(body *ast.BlockStmt, parallel *testCall) func(token.Pos) bool {
+	var deferred []*ast.DeferStmt
+	ast.Inspect(body, func(n ast.Node) bool {
+		if d, ok := n.(*ast.DeferStmt); ok {
+			deferred = append(deferred, d)
+		}
+		return true
+	})
+	return func(pos token.Pos) bool {
+		if pos > parallel.pos {
+			return true
+		}
+		for _, d := range deferred {
+			if d.Pos() <= pos && pos < d.End() {
+				return true
+			}
+		}
+		return false
+	}
+}
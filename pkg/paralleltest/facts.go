@@ -0,0 +1,139 @@
+package paralleltest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// helperFact describes how an exported function taking a *testing.T or a
+// testing.TB affects the test it is called with. Packages that import the
+// function, such as the tests calling testutil.WithEnv(t, ...), use the fact
+// in place of the declaration they can't see.
+type helperFact struct {
+	// Parallel reports whether the function calls t.Parallel.
+	Parallel bool
+	// CantParallel reports whether the function prevents the test from calling t.Parallel.
+	CantParallel bool
+	// Reason describes the call that prevents t.Parallel.
+	Reason string
+	// Subtests is the number of t.Run calls of the function.
+	Subtests int
+	// ParallelCall and EnvCall are the first calls of the function to
+	// t.Parallel and to t.Setenv or t.Chdir, if any.
+	ParallelCall, EnvCall *callFact
+}
+
+func (*helperFact) AFact() {}
+
+// callFact describes a call of an exported function to a method of its T.
+type callFact struct {
+	// Name is the name of the method.
+	Name string
+	// Placement is where the function calls the method.
+	Placement placement
+	// External marks a call whose name is qualified with its package.
+	External bool
+}
+
+// exportCall returns the fact of a call, or nil if there is no call.
+func exportCall(call *testCall) *callFact {
+	if call == nil {
+		return nil
+	}
+	return &callFact{Name: call.name, Placement: call.placement, External: call.external}
+}
+
+// importCall returns the call described by a fact, or nil if there is no
+// fact. The call has no position until it is recorded at the call of the
+// function, see testCall.through.
+func importCall(fact *callFact) *testCall {
+	if fact == nil {
+		return nil
+	}
+	return &testCall{name: fact.Name, placement: fact.Placement, external: fact.External}
+}
+
+func (f *helperFact) String() string {
+	var parts []string
+	if f.Parallel {
+		parts = append(parts, "parallel")
+	}
+	if f.CantParallel {
+		parts = append(parts, "cantParallel: "+f.Reason)
+	}
+	if f.Subtests > 0 {
+		parts = append(parts, fmt.Sprintf("subtests: %d", f.Subtests))
+	}
+	if len(parts) == 0 {
+		return "no effect on t.Parallel"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// exportHelperFacts exports a helperFact for each exported function or method
+// that takes a *testing.T or a testing.TB. Only functions outside of _test.go
+// files can be imported by other packages.
+func (a *parallelAnalyzer) exportHelperFacts(pass *analysis.Pass) {
+	// Diagnostics belong to the tests reaching the helper, which have been
+	// analyzed already, so helpers are analyzed without reporting.
+	quiet := quietPass(pass)
+
+	for _, file := range pass.Files {
+		if isTestFile(pass.Fset.File(file.Pos()).Name()) {
+			continue
+		}
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Body == nil || isTestFunction(pass, funcDecl) {
+				continue
+			}
+			fn, ok := pass.TypesInfo.Defs[funcDecl.Name].(*types.Func)
+			if !ok || !fn.Exported() || findTestParam(pass, funcDecl.Type.Params) == nil {
+				continue
+			}
+
+			result := a.analyzeFunction(quiet, funcDecl)
+			pass.ExportObjectFact(fn, &helperFact{
+				Parallel:     result.hasParallel,
+				CantParallel: result.cantParallel,
+				Reason:       result.cantParallelReason,
+				Subtests:     result.numberOfTestRun,
+				ParallelCall: exportCall(result.parallelCall),
+				EnvCall:      exportCall(result.envCall),
+			})
+		}
+	}
+}
+
+// importedAnalysis returns the analysis of a function declared in another
+// package from its helperFact, or nil if the package exported none.
+func importedAnalysis(pass *analysis.Pass, fn *types.Func) *testAnalysis {
+	var fact helperFact
+	if fn == nil || fn.Pkg() == pass.Pkg || !pass.ImportObjectFact(fn.Origin(), &fact) {
+		return nil
+	}
+	imported := &testAnalysis{
+		hasParallel:        fact.Parallel,
+		cantParallel:       fact.CantParallel,
+		cantParallelReason: fact.Reason,
+		numberOfTestRun:    fact.Subtests,
+		parallelCall:       importCall(fact.ParallelCall),
+		envCall:            importCall(fact.EnvCall),
+	}
+	if imported.parallelCall != nil {
+		imported.parallelCalls = []*testCall{imported.parallelCall}
+	}
+	return imported
+}
+
+// callReason describes a call to the object as a reason that prevents t.Parallel.
+func callReason(obj types.Object) string {
+	if fn, ok := obj.(*types.Func); ok {
+		return "calls " + fn.FullName()
+	}
+	return "calls " + obj.Name()
+}
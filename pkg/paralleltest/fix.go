@@ -0,0 +1,350 @@
+package paralleltest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// parallelFix returns a suggested fix that inserts a call to Parallel as the
+// first statement of the given test body. The call is placed after any leading
+// t.Helper() or t.Skip guard. No fix is returned if the test parameter is unnamed.
+func parallelFix(pass *analysis.Pass, funcType *ast.FuncType, body *ast.BlockStmt) []analysis.SuggestedFix {
+	testVar := findTestParam(pass, funcType.Params)
+	if testVar == nil || body == nil {
+		return nil
+	}
+
+	return []analysis.SuggestedFix{{
+		Message:   fmt.Sprintf("Add call to %s.Parallel()", testVar.Name()),
+		TextEdits: []analysis.TextEdit{parallelEdit(pass, body, testVar.Name(), countGuards(pass, body, testVar))},
+	}}
+}
+
+// parallelEdit inserts a call to Parallel on testVar after the first guards
+// statements of body.
+func parallelEdit(pass *analysis.Pass, body *ast.BlockStmt, testVar string, guards int) analysis.TextEdit {
+	call := testVar + ".Parallel()"
+
+	switch {
+	case guards < len(body.List):
+		stmt := body.List[guards]
+		indent := indentation(pass.Fset, stmt.Pos())
+		return analysis.TextEdit{Pos: stmt.Pos(), End: stmt.Pos(), NewText: []byte(call + "\n" + indent)}
+	case guards > 0:
+		last := body.List[guards-1]
+		indent := indentation(pass.Fset, last.Pos())
+		return analysis.TextEdit{Pos: last.End(), End: last.End(), NewText: []byte("\n" + indent + call)}
+	default:
+		return analysis.TextEdit{Pos: body.Lbrace + 1, End: body.Lbrace + 1, NewText: []byte("\n" + call + "\n")}
+	}
+}
+
+// nameTestParamFix returns a suggested fix that names the unnamed or blank
+// *testing.T parameter of the test and calls Parallel on it.
+func nameTestParamFix(pass *analysis.Pass, funcDecl *ast.FuncDecl) []analysis.SuggestedFix {
+	if funcDecl.Body == nil {
+		return nil
+	}
+	param := funcDecl.Type.Params.List[0]
+	name := freshName("t", identNames(funcDecl))
+
+	var nameEdit analysis.TextEdit
+	if len(param.Names) > 0 {
+		nameEdit = analysis.TextEdit{Pos: param.Names[0].Pos(), End: param.Names[0].End(), NewText: []byte(name)}
+	} else {
+		nameEdit = analysis.TextEdit{Pos: param.Type.Pos(), End: param.Type.Pos(), NewText: []byte(name + " ")}
+	}
+
+	return []analysis.SuggestedFix{{
+		Message:   fmt.Sprintf("Name the parameter %s and add call to %s.Parallel()", name, name),
+		TextEdits: []analysis.TextEdit{nameEdit, parallelEdit(pass, funcDecl.Body, name, 0)},
+	}}
+}
+
+// countGuards returns the number of leading statements in the body that call
+// t.Helper() or one of the t.Skip methods.
+func countGuards(pass *analysis.Pass, body *ast.BlockStmt, testVar types.Object) int {
+	for i, stmt := range body.List {
+		exprStmt, ok := stmt.(*ast.ExprStmt)
+		if !ok {
+			return i
+		}
+		callExpr, ok := exprStmt.X.(*ast.CallExpr)
+		if !ok || !isGuardCall(pass, callExpr, testVar) {
+			return i
+		}
+	}
+	return len(body.List)
+}
+
+// isGuardCall reports whether the call may stay ahead of t.Parallel().
+func isGuardCall(pass *analysis.Pass, callExpr *ast.CallExpr, testVar types.Object) bool {
+	return exprCallHasMethod(pass, callExpr, testVar, "Helper") ||
+		exprCallHasMethod(pass, callExpr, testVar, "Skip") ||
+		exprCallHasMethod(pass, callExpr, testVar, "Skipf") ||
+		exprCallHasMethod(pass, callExpr, testVar, "SkipNow")
+}
+
+// indentation returns the leading tabs of a gofmt-ed line starting at pos.
+func indentation(fset *token.FileSet, pos token.Pos) string {
+	return strings.Repeat("\t", fset.Position(pos).Column-1)
+}
+
+// cleanupFix returns a suggested fix that rewrites the run of consecutive
+// defer statements around deferStmt into a single t.Cleanup call. The deferred
+// calls keep their LIFO order, and values that defer would have evaluated
+// immediately are captured into locals first. No fix is returned if a deferred
+// function calls recover, which can't stop a panic of the test from t.Cleanup.
+func cleanupFix(pass *analysis.Pass, funcType *ast.FuncType, body *ast.BlockStmt, deferStmt *ast.DeferStmt) []analysis.SuggestedFix {
+	testVar := findTestParam(pass, funcType.Params)
+	if testVar == nil {
+		return nil
+	}
+	run := deferRun(body, deferStmt)
+	if len(run) == 0 || slices.ContainsFunc(run, func(d *ast.DeferStmt) bool { return callsRecover(pass, d.Call) }) {
+		return nil
+	}
+	tokFile := pass.Fset.File(deferStmt.Pos())
+	src, err := pass.ReadFile(tokFile.Name())
+	if err != nil {
+		return nil
+	}
+	text := func(from, to token.Pos) string {
+		return string(src[tokFile.Offset(from):tokFile.Offset(to)])
+	}
+
+	taken := identNames(body)
+	// The defer statements before the run are fixed on their own, the names
+	// they capture into are taken as well.
+	for _, stmt := range body.List {
+		if d, ok := stmt.(*ast.DeferStmt); ok && d.Pos() < run[0].Pos() {
+			deferredCall(pass, d.Call, text, func(expr ast.Expr) string {
+				if needsCapture(pass, body, expr) {
+					freshName(captureBase(expr), taken)
+				}
+				return ""
+			})
+		}
+	}
+	indent := indentation(pass.Fset, run[0].Pos())
+	edits := make([]analysis.TextEdit, 0, len(run))
+	calls := make([]string, 0, len(run))
+	for i, d := range run {
+		var captures []string
+		capture := func(expr ast.Expr) string {
+			if !needsCapture(pass, body, expr) {
+				return text(expr.Pos(), expr.End())
+			}
+			name := freshName(captureBase(expr), taken)
+			captures = append(captures, name+" := "+text(expr.Pos(), expr.End()))
+			return name
+		}
+		call := deferredCall(pass, d.Call, text, capture)
+
+		newText := strings.Join(captures, "\n"+indent)
+		start, end := d.Pos(), d.End()
+		if i < len(run)-1 && newText == "" {
+			// Remove the line of the defer statement, the comment that follows
+			// it moves along with the call.
+			lineStart := tokFile.LineStart(tokFile.Line(start))
+			rest, _, found := strings.Cut(string(src[tokFile.Offset(end):]), "\n")
+			comment := strings.TrimSpace(rest)
+			if found && strings.TrimSpace(text(lineStart, start)) == "" && (comment == "" || strings.HasPrefix(comment, "//")) {
+				start, end = lineStart, end+token.Pos(len(rest)+1)
+				if comment != "" {
+					call += " " + comment
+				}
+			}
+		}
+		calls = append(calls, call)
+		if len(run) == 1 && isPlainFuncLit(d.Call) {
+			// A lone function literal is registered as is.
+			newText += testVar.Name() + ".Cleanup(" + text(d.Call.Fun.Pos(), d.Call.Fun.End()) + ")"
+		} else if i == len(run)-1 {
+			var cleanup strings.Builder
+			cleanup.WriteString(testVar.Name() + ".Cleanup(func() {\n")
+			// t.Cleanup runs its functions in LIFO order as well, but within a
+			// single function the calls have to be reversed by hand.
+			for j := len(calls) - 1; j >= 0; j-- {
+				if calls[j] != "" {
+					cleanup.WriteString(indent + "\t" + calls[j] + "\n")
+				}
+			}
+			cleanup.WriteString(indent + "})")
+			if newText != "" {
+				newText += "\n" + indent
+			}
+			newText += cleanup.String()
+		}
+		edits = append(edits, analysis.TextEdit{Pos: start, End: end, NewText: []byte(newText)})
+	}
+
+	return []analysis.SuggestedFix{{
+		Message:   fmt.Sprintf("Replace defer with %s.Cleanup", testVar.Name()),
+		TextEdits: edits,
+	}}
+}
+
+// deferredCall returns the source of the deferred call, with the callee and
+// arguments passed through capture. Function literals stay calls rather than
+// being unwrapped, so that their return statements don't skip the calls
+// merged after them.
+func deferredCall(pass *analysis.Pass, call *ast.CallExpr, text func(from, to token.Pos) string, capture func(ast.Expr) string) string {
+	fun := text(call.Fun.Pos(), call.Fun.End())
+	switch f := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		fun = capture(f.X) + "." + f.Sel.Name
+	case *ast.Ident:
+		// A function variable, such as the cancel func of a context, may be
+		// reassigned after the defer statement.
+		if _, ok := pass.TypesInfo.Uses[f].(*types.Var); ok {
+			fun = capture(f)
+		}
+	}
+	args := make([]string, 0, len(call.Args))
+	for _, arg := range call.Args {
+		args = append(args, capture(arg))
+	}
+	ellipsis := ""
+	if call.Ellipsis.IsValid() {
+		ellipsis = "..."
+	}
+	return fun + "(" + strings.Join(args, ", ") + ellipsis + ")"
+}
+
+// callsRecover reports whether the deferred call is a function literal that
+// calls recover.
+func callsRecover(pass *analysis.Pass, call *ast.CallExpr) bool {
+	funcLit, ok := call.Fun.(*ast.FuncLit)
+	if !ok {
+		return false
+	}
+	recovers := false
+	ast.Inspect(funcLit.Body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if ident, ok := ast.Unparen(call.Fun).(*ast.Ident); ok {
+				if b, ok := pass.TypesInfo.Uses[ident].(*types.Builtin); ok && b.Name() == "recover" {
+					recovers = true
+				}
+			}
+		}
+		return !recovers
+	})
+	return recovers
+}
+
+// isPlainFuncLit reports whether the call calls a function literal without
+// arguments, which t.Cleanup can take in place of the call.
+func isPlainFuncLit(call *ast.CallExpr) bool {
+	_, ok := call.Fun.(*ast.FuncLit)
+	return ok && len(call.Args) == 0
+}
+
+// deferRun returns the consecutive defer statements of body that include deferStmt.
+func deferRun(body *ast.BlockStmt, deferStmt *ast.DeferStmt) []*ast.DeferStmt {
+	var run []*ast.DeferStmt
+	for _, stmt := range body.List {
+		d, ok := stmt.(*ast.DeferStmt)
+		if !ok {
+			if slices.Contains(run, deferStmt) {
+				return run
+			}
+			run = nil
+			continue
+		}
+		run = append(run, d)
+	}
+	if slices.Contains(run, deferStmt) {
+		return run
+	}
+	return nil
+}
+
+// needsCapture reports whether the value of expr may differ between the defer
+// statement and the end of the test. Constants, function literals, package
+// names and variables that are never reassigned in body are stable.
+func needsCapture(pass *analysis.Pass, body *ast.BlockStmt, expr ast.Expr) bool {
+	if tv, ok := pass.TypesInfo.Types[expr]; ok && (tv.Value != nil || tv.IsNil() || tv.IsType()) {
+		return false
+	}
+	switch e := expr.(type) {
+	case *ast.FuncLit:
+		return false
+	case *ast.Ident:
+		switch obj := pass.TypesInfo.ObjectOf(e).(type) {
+		case *types.PkgName, *types.Func:
+			return false
+		case *types.Var:
+			return isReassigned(pass, body, obj)
+		}
+	}
+	return true
+}
+
+// isReassigned reports whether the variable is assigned, incremented or has its
+// address taken anywhere in body.
+func isReassigned(pass *analysis.Pass, body *ast.BlockStmt, obj *types.Var) bool {
+	refersTo := func(expr ast.Expr) bool {
+		ident, ok := ast.Unparen(expr).(*ast.Ident)
+		return ok && pass.TypesInfo.Uses[ident] == obj
+	}
+
+	reassigned := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.AssignStmt:
+			reassigned = reassigned || slices.ContainsFunc(v.Lhs, refersTo)
+		case *ast.IncDecStmt:
+			reassigned = reassigned || refersTo(v.X)
+		case *ast.UnaryExpr:
+			reassigned = reassigned || (v.Op == token.AND && refersTo(v.X))
+		case *ast.RangeStmt:
+			reassigned = reassigned || (v.Tok == token.ASSIGN && (refersTo(v.Key) || refersTo(v.Value)))
+		}
+		return !reassigned
+	})
+	return reassigned
+}
+
+// captureBase returns the name a captured expression is stored under.
+func captureBase(expr ast.Expr) string {
+	var name string
+	switch e := expr.(type) {
+	case *ast.Ident:
+		name = e.Name
+	case *ast.SelectorExpr:
+		name = e.Sel.Name
+	default:
+		name = "arg"
+	}
+	return "deferred" + strings.ToUpper(name[:1]) + name[1:]
+}
+
+// freshName returns base, or base with a numeric suffix, that is not in taken
+// and marks it as taken.
+func freshName(base string, taken map[string]bool) string {
+	name := base
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	taken[name] = true
+	return name
+}
+
+// identNames returns the set of identifier names used in node.
+func identNames(node ast.Node) map[string]bool {
+	names := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			names[ident.Name] = true
+		}
+		return true
+	})
+	return names
+}
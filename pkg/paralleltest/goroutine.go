@@ -0,0 +1,129 @@
+package paralleltest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Testify's require package, whose assertions call FailNow on failure.
+const requirePackage = "github.com/stretchr/testify/require"
+
+// goroutine is a function literal run in a goroutine started by a function.
+type goroutine struct {
+	// start is the go statement or the call that starts the goroutine.
+	start ast.Node
+	body  *ast.BlockStmt
+}
+
+// reportGoroutines reports the goroutines started in the body of a test, with
+// go statements, errgroup.Group.Go or sync.WaitGroup.Go, that call FailNow or
+// one of the methods and assertions calling it on a T. FailNow must be called
+// from the goroutine running the test. The goroutines that log or report
+// errors on a T are reported too when the body doesn't wait for anything
+// after starting them, with a receive, a select or the Wait method of a
+// sync.WaitGroup or an errgroup.Group, since the calls panic once the test has
+// completed. The literals of subtests are reported with their own body.
+func reportGoroutines(pass *analysis.Pass, body *ast.BlockStmt) {
+	var goroutines []goroutine
+	var waits []ast.Node
+	subtests := make(map[*ast.FuncLit]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return !subtests[n]
+		case *ast.GoStmt:
+			if lit, ok := ast.Unparen(n.Call.Fun).(*ast.FuncLit); ok {
+				goroutines = append(goroutines, goroutine{n, lit.Body})
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				waits = append(waits, n)
+			}
+		case *ast.SelectStmt:
+			waits = append(waits, n)
+		case *ast.CallExpr:
+			fn := calleeFunc(pass, n)
+			if fn == nil {
+				return true
+			}
+			switch fn.FullName() {
+			case "(*golang.org/x/sync/errgroup.Group).Go", "(*golang.org/x/sync/errgroup.Group).TryGo", "(*sync.WaitGroup).Go":
+				if len(n.Args) != 1 {
+					break
+				}
+				if lit, ok := ast.Unparen(n.Args[0]).(*ast.FuncLit); ok {
+					goroutines = append(goroutines, goroutine{n, lit.Body})
+				}
+			case "(*golang.org/x/sync/errgroup.Group).Wait", "(*sync.WaitGroup).Wait":
+				waits = append(waits, n)
+			}
+			if fn.Name() == "Run" && isTestingObject(fn) && fn.Signature().Recv() != nil {
+				if args := methodArgs(pass, n); len(args) > 1 {
+					if lit, ok := args[1].(*ast.FuncLit); ok {
+						subtests[lit] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	for _, g := range goroutines {
+		waited := false
+		for _, wait := range waits {
+			if wait.Pos() >= g.start.End() {
+				waited = true
+				break
+			}
+		}
+		reportGoroutine(pass, g, waited)
+	}
+}
+
+// reportGoroutine reports the calls of a goroutine to FailNow and the methods
+// that call it, and to the methods that log if the goroutine isn't waited for.
+func reportGoroutine(pass *analysis.Pass, g goroutine, waited bool) {
+	ast.Inspect(g.body, func(n ast.Node) bool {
+		callExpr, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		fn := calleeFunc(pass, callExpr)
+		if fn == nil {
+			return true
+		}
+		if fn.Pkg() != nil && fn.Pkg().Path() == requirePackage && fn.Signature().Recv() == nil {
+			pass.Report(analysis.Diagnostic{
+				Pos: callExpr.Pos(),
+				Message: fmt.Sprintf("Goroutine calls require.%s, which calls FailNow on failure: FailNow must be called from the goroutine running the test\n",
+					fn.Name()),
+			})
+			return true
+		}
+
+		receiver := methodReceiver(pass, callExpr)
+		if receiver == nil || !isTestingObject(fn) || !isTestingTB(pass, pass.TypesInfo.TypeOf(receiver)) {
+			return true
+		}
+		name := types.ExprString(receiver) + "." + fn.Name()
+		switch fn.Name() {
+		case "FailNow", "Fatal", "Fatalf", "SkipNow", "Skip", "Skipf":
+			pass.Report(analysis.Diagnostic{
+				Pos:     callExpr.Pos(),
+				Message: fmt.Sprintf("Goroutine calls %s: FailNow and the methods calling it must be called from the goroutine running the test\n", name),
+			})
+		case "Log", "Logf", "Error", "Errorf", "Fail":
+			if !waited {
+				pass.Report(analysis.Diagnostic{
+					Pos:     callExpr.Pos(),
+					Message: fmt.Sprintf("Goroutine calls %s and the test doesn't wait for it, which panics once the test has completed\n", name),
+				})
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,125 @@
+package paralleltest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// orderCall returns the call if it should follow the call to t.Parallel: a
+// call to t.Run, which starts a subtest, or a call that blocks on I/O or
+// sleeps. It returns nil otherwise.
+func orderCall(pass *analysis.Pass, callExpr *ast.CallExpr, testVar types.Object) *testCall {
+	if isTestRunCall(pass, callExpr, testVar) {
+		return methodCall(callExpr, "Run")
+	}
+	if fn := calleeFunc(pass, callExpr); fn != nil && isBlockingCall(fn) {
+		call := methodCall(callExpr, fn.FullName())
+		call.external = true
+		return call
+	}
+	return nil
+}
+
+// firstOrderCall returns the first of the calls that the function makes
+// before it returns, the calls of goroutines, deferred functions and
+// t.Cleanup functions are left out.
+func firstOrderCall(calls []*testCall) *testCall {
+	var first *testCall
+	for _, call := range calls {
+		if call.placement < placedInCleanup && (first == nil || call.pos < first.pos) {
+			first = call
+		}
+	}
+	return first
+}
+
+// isBlockingCall reports whether the function of the standard library blocks
+// on files, the network, other processes or a timer.
+func isBlockingCall(fn *types.Func) bool {
+	switch fn.FullName() {
+	case "time.Sleep",
+		"os.Open", "os.OpenFile", "os.Create", "os.CreateTemp", "os.ReadFile", "os.WriteFile",
+		"os.ReadDir", "os.Mkdir", "os.MkdirAll", "os.MkdirTemp", "os.Remove", "os.RemoveAll",
+		"os.Rename", "os.Stat", "os.Lstat",
+		"(*os.File).Read", "(*os.File).ReadAt", "(*os.File).Write", "(*os.File).WriteAt",
+		"(*os.File).WriteString", "(*os.File).ReadDir", "(*os.File).Sync",
+		"io.ReadAll", "io.Copy", "io.CopyN", "io.CopyBuffer", "io.ReadFull", "io.ReadAtLeast",
+		"net.Dial", "net.DialTimeout", "net.Listen", "net.ListenPacket", "net.LookupHost", "net.LookupIP",
+		"net/http.Get", "net/http.Head", "net/http.Post", "net/http.PostForm",
+		"(*net/http.Client).Do", "(*net/http.Client).Get", "(*net/http.Client).Head",
+		"(*net/http.Client).Post", "(*net/http.Client).PostForm",
+		"(*os/exec.Cmd).Run", "(*os/exec.Cmd).Output", "(*os/exec.Cmd).CombinedOutput", "(*os/exec.Cmd).Wait",
+		"(*database/sql.DB).Ping", "(*database/sql.DB).PingContext",
+		"(*database/sql.DB).Exec", "(*database/sql.DB).ExecContext",
+		"(*database/sql.DB).Query", "(*database/sql.DB).QueryContext",
+		"(*database/sql.DB).QueryRow", "(*database/sql.DB).QueryRowContext":
+		return true
+	default:
+		return false
+	}
+}
+
+// reportParallelOrder reports a test that starts a subtest or blocks before it
+// calls t.Parallel. Until then the test holds up the tests that run serially,
+// and its first subtests run before it is parallel. A t.Setenv or t.Chdir
+// before t.Parallel is reported by reportEnvConflict, since t.Parallel can't
+// come first either.
+func (a *parallelAnalyzer) reportParallelOrder(pass *analysis.Pass, result *testAnalysis, name string, testVar types.Object, body *ast.BlockStmt) {
+	if len(result.parallelCalls) == 0 || result.parallelCalls[0].placement != placedInBody {
+		return
+	}
+	parallel := result.parallelCalls[0]
+	order := firstOrderCall(result.orderCalls)
+	if order == nil || order.pos >= parallel.pos {
+		return
+	}
+
+	pass.Report(analysis.Diagnostic{
+		Pos: parallel.pos,
+		Message: fmt.Sprintf("Function %s calls %s after %s, t.Parallel must come first so that the test doesn't hold up the serial tests\n",
+			name, parallel.describe(testVar.Name()), order.describe(testVar.Name())),
+		Related:        []analysis.RelatedInformation{{Pos: order.method, Message: "call to " + order.name}},
+		SuggestedFixes: parallelFirstFix(pass, body, parallel, order, order.describe(testVar.Name())),
+	})
+}
+
+// parallelFirstFix returns a suggested fix that moves the statement calling
+// t.Parallel before the statement making the order call, described by orderName. No fix is returned
+// if t.Parallel is called through a helper, which may depend on the
+// statements before it, or not in a statement of the body itself.
+func parallelFirstFix(pass *analysis.Pass, body *ast.BlockStmt, parallel, order *testCall, orderName string) []analysis.SuggestedFix {
+	if parallel.helper != "" {
+		return nil
+	}
+	var stmt, before ast.Stmt
+	for _, s := range body.List {
+		if before == nil && s.Pos() <= order.pos && order.pos < s.End() {
+			before = s
+		}
+		if exprStmt, ok := s.(*ast.ExprStmt); ok && ast.Unparen(exprStmt.X) == parallel.call {
+			stmt = exprStmt
+		}
+	}
+	if stmt == nil || before == nil {
+		return nil
+	}
+
+	// The lines of the statement are removed, with its comments.
+	file := pass.Fset.File(stmt.Pos())
+	start, end := file.LineStart(file.Line(stmt.Pos())), stmt.End()
+	if line := file.Line(stmt.End()); line < file.LineCount() {
+		end = file.LineStart(line + 1)
+	}
+	call := types.ExprString(parallel.call)
+
+	return []analysis.SuggestedFix{{
+		Message: fmt.Sprintf("Move %s before %s", call, orderName),
+		TextEdits: []analysis.TextEdit{
+			{Pos: before.Pos(), End: before.Pos(), NewText: []byte(call + "\n" + indentation(pass.Fset, before.Pos()))},
+			{Pos: start, End: end},
+		},
+	}}
+}
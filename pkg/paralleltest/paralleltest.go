@@ -2,11 +2,16 @@ package paralleltest
 
 import (
 	"flag"
+	"fmt"
 	"go/ast"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
 	"strings"
-	"sync"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
 	"golang.org/x/tools/go/ast/inspector"
 )
 
@@ -21,177 +26,651 @@ type Config struct {
 	IgnoreMissingSubtests bool `json:"ignoreMissingSubtests"`
 	// CheckCleanup check that defer is not used with t.Parallel (use t.Cleanup instead)
 	CheckCleanup bool `json:"checkCleanup"`
+	// CheckConditionalParallel check that t.Parallel is not only called under a condition
+	CheckConditionalParallel bool `json:"checkConditionalParallel"`
+	// CheckParallelFirst check that t.Parallel is called before any subtest or blocking call
+	CheckParallelFirst bool `json:"checkParallelFirst"`
 	// ExtraSigs is a list of extra functions that cannot be used with t.Parallel
 	ExtraSigs []string `json:"extraSigs"`
+	// Engine selects how the calls a test reaches are found: "ast" (the default)
+	// follows the syntax tree, "cha" and "vta" also follow an SSA call graph of
+	// the current package, without the bodies of the functions of other packages
+	Engine string `json:"engine"`
 }
 
 func NewAnalyzer(config Config) *analysis.Analyzer {
-	a := &parallelAnalyzer{
-		config:  config,
-		mu:      &sync.RWMutex{},
-		visited: make(map[string]*testAnalysis),
-	}
+	a := &parallelAnalyzer{config: config}
 
+	// The flags default to the given config, so that they only override it when set.
 	var ignoreLoopVar bool
 	var flags flag.FlagSet
-	flags.BoolVar(&config.IgnoreMissing, "i", false, "ignore missing calls to t.Parallel")
-	flags.BoolVar(&config.IgnoreMissingSubtests, "ignoremissingsubtests", false, "ignore missing calls to t.Parallel in subtests")
+	flags.BoolVar(&a.config.IgnoreMissing, "i", config.IgnoreMissing, "ignore missing calls to t.Parallel")
+	flags.BoolVar(&a.config.IgnoreMissingSubtests, "ignoremissingsubtests", config.IgnoreMissingSubtests, "ignore missing calls to t.Parallel in subtests")
 	flags.BoolVar(&ignoreLoopVar, "ignoreloopVar", false, "ignore loop variable detection <deprecated with go 1.22>")
-	flags.BoolVar(&config.CheckCleanup, "checkcleanup", false, "check that defer is not used with t.Parallel (use t.Cleanup instead)")
+	flags.BoolVar(&a.config.CheckCleanup, "checkcleanup", config.CheckCleanup, "check that defer is not used with t.Parallel (use t.Cleanup instead)")
+	flags.BoolVar(&a.config.CheckConditionalParallel, "checkconditional", config.CheckConditionalParallel, "check that t.Parallel is not only called under a condition")
+	flags.BoolVar(&a.config.CheckParallelFirst, "checkparallelfirst", config.CheckParallelFirst, "check that t.Parallel is called before any subtest or blocking call")
+	flags.StringVar(&a.config.Engine, "engine", config.Engine, `how the calls a test reaches are found: "ast", "cha" or "vta" (default "ast"); cha and vta only follow calls within the current package`)
 
 	return &analysis.Analyzer{
-		Name:  "paralleltest",
-		Doc:   Doc,
-		Run:   a.run,
-		Flags: flags,
+		Name:      "paralleltest",
+		Doc:       Doc,
+		Run:       a.run,
+		Requires:  []*analysis.Analyzer{inspect.Analyzer},
+		Flags:     flags,
+		FactTypes: []analysis.Fact{new(helperFact)},
 	}
 }
 
 // parallelAnalyzer is an internal analyzer that makes options available to a
 // run pass. It wraps an `analysis.Analyzer` that should be returned for
-// linters.
+// linters. Each pass runs with its own copy, which holds the state of the pass.
 type parallelAnalyzer struct {
-	config  Config
-	mu      *sync.RWMutex
-	visited map[string]*testAnalysis
+	config Config
+	// visited caches the analysis of each function body of the package.
+	visited map[*ast.BlockStmt]*testAnalysis
+	// decls indexes the function and method declarations of the package.
+	decls map[*types.Func]*ast.FuncDecl
+	// callGraph follows the calls of the package with an SSA engine, it is nil
+	// with the ast engine.
+	callGraph *callGraph
+	// bubbles holds the T parameters of the functions run by synctest.Test.
+	bubbles map[types.Object]bool
+	// round counts the walks of recursive functions, see analyzeFunctionF.
+	round int
 }
 
 type testAnalysis struct {
 	hasParallel,
 	cantParallel,
 	funcHasDeferStatement bool
+	// cantParallelReason describes the first call that prevents t.Parallel.
+	cantParallelReason string
+	// chain is the call chain to a call that prevents t.Parallel that the
+	// call graph found, it is empty with the ast engine.
+	chain           string
 	numberOfTestRun int
-	deferStatements []ast.Node
+	deferStatements []*ast.DeferStmt
+	// inProgress marks the analysis of a function that is still being walked.
+	inProgress bool
+	// parallelCall and envCall are the first calls of the function, directly or
+	// through the helpers it calls, to t.Parallel and to t.Setenv or t.Chdir on
+	// its own T. The calls of its subtests are not included.
+	parallelCall, envCall *testCall
+	// parallelCalls holds all the calls of the function to t.Parallel on its
+	// T, directly or through the helpers it calls.
+	parallelCalls []*testCall
+	// orderCalls holds the calls of the function, directly or through the
+	// helpers it calls, that start a subtest or block, with CheckParallelFirst.
+	orderCalls []*testCall
+	// subtests holds the subtests the function starts on its T, directly or
+	// through the helpers it calls.
+	subtests []subtest
+	// pending holds the bodies of the functions in progress whose partial
+	// analysis was merged into this one. The analysis is final once it is empty.
+	pending map[*ast.BlockStmt]bool
+	// round is the round of the walk of the recursive functions the analysis
+	// was found in.
+	round int
 }
 
 func (a *testAnalysis) merge(other *testAnalysis) {
 	a.hasParallel = a.hasParallel || other.hasParallel
-	a.cantParallel = a.cantParallel || other.cantParallel
+	if other.cantParallel {
+		a.markCantParallel(other.cantParallelReason)
+	}
 	a.numberOfTestRun += other.numberOfTestRun
+	if len(other.pending) > 0 {
+		// Copies of an analysis share the map, so it is never updated in place.
+		pending := maps.Clone(other.pending)
+		maps.Copy(pending, a.pending)
+		a.pending = pending
+	}
+}
+
+// testCall is a call of a function to a method of its T, or to a helper that
+// calls the method. It is also a blocking call that should follow t.Parallel.
+type testCall struct {
+	// call is the call in the function, to the method or to the helper.
+	call *ast.CallExpr
+	// pos is the position of the call in the function.
+	pos token.Pos
+	// method is the position of the call to the method, in the function or in the helper.
+	method token.Pos
+	// name is the name of the method.
+	name string
+	// helper is the name of the helper called by the function, if any.
+	helper string
+	// placement is where the method is called, in the function or in the helper.
+	placement placement
+	// external marks a call to a function, or to a method of another type
+	// than T, whose name is qualified with its package.
+	external bool
+}
+
+// subtest is a subtest started with t.Run.
+type subtest struct {
+	// name describes the name of the subtest.
+	name string
+	// run is the position of the call to t.Run, or of the call to the helper
+	// that calls it, in the function starting the subtest.
+	run      token.Pos
+	funcType *ast.FuncType
+	body     *ast.BlockStmt
+}
+
+// describe describes the call, with the name of the test variable of the function.
+func (c *testCall) describe(testVar string) string {
+	name := testVar + "." + c.name
+	if c.external {
+		name = c.name
+	}
+	if c.helper == "" {
+		return name
+	}
+	return fmt.Sprintf("%s through %s", name, c.helper)
 }
 
-// getAnalysis returns the cached analysis for the given node, or nil if it has not been visited yet.
-func (a *parallelAnalyzer) getAnalysis(node ast.Node) (string, *testAnalysis) {
-	hash := nodeHash(node)
-	a.mu.RLock()
-	defer a.mu.RUnlock()
-	if v, ok := a.visited[hash]; ok {
-		return hash, v
+// through returns the call of a helper as a call of the function calling the
+// helper with callExpr. A call imported from another package is located at
+// callExpr.
+func (c *testCall) through(callExpr *ast.CallExpr, helperName string) *testCall {
+	method := c.method
+	if !method.IsValid() {
+		method = callExpr.Pos()
+	}
+	return &testCall{
+		call:      callExpr,
+		pos:       callExpr.Pos(),
+		method:    method,
+		name:      c.name,
+		helper:    helperName,
+		placement: c.placement,
+		external:  c.external,
 	}
-	return hash, nil
 }
 
-// cacheAnalysis caches the given analysis for the given node.
-func (a *parallelAnalyzer) cacheAnalysis(hash string, analysis *testAnalysis) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	a.visited[hash] = analysis
+// methodCall returns the call to the method with the given name.
+func methodCall(callExpr *ast.CallExpr, name string) *testCall {
+	return &testCall{call: callExpr, pos: callExpr.Pos(), method: callExpr.Pos(), name: name}
+}
+
+// recordHelperCalls records the calls of a helper to the methods of the T it
+// is called with, as calls of the function at the call to the helper.
+//
+// A helper that calls another one twice would double the calls at each level,
+// so at most two calls to the same t.Parallel with the same placement are
+// recorded, which is enough to tell that it is called more than once, and each
+// subtest is recorded once.
+func (a *testAnalysis) recordHelperCalls(helper *testAnalysis, callExpr *ast.CallExpr, helperName string) {
+	through := func(call **testCall, helperCall *testCall) {
+		if *call == nil && helperCall != nil {
+			*call = helperCall.through(callExpr, helperName)
+		}
+	}
+	through(&a.parallelCall, helper.parallelCall)
+	through(&a.envCall, helper.envCall)
+	type key struct {
+		method    token.Pos
+		placement placement
+	}
+	seen := make(map[key]int)
+	for _, call := range helper.parallelCalls {
+		k := key{call.method, call.placement}
+		if seen[k] < 2 {
+			seen[k]++
+			a.parallelCalls = append(a.parallelCalls, call.through(callExpr, helperName))
+		}
+	}
+	if call := firstOrderCall(helper.orderCalls); call != nil {
+		a.orderCalls = append(a.orderCalls, call.through(callExpr, helperName))
+	}
+	recorded := make(map[*ast.BlockStmt]bool)
+	for _, sub := range helper.subtests {
+		if !recorded[sub.body] {
+			recorded[sub.body] = true
+			sub.run = callExpr.Pos()
+			a.subtests = append(a.subtests, sub)
+		}
+	}
+}
+
+// final reports whether the analysis is complete. A partial analysis is merged
+// from a recursive call and completed by a later walk.
+func (a *testAnalysis) final() bool {
+	return !a.inProgress && len(a.pending) == 0
+}
+
+// sameEffects reports whether both analyses found the same effects on t.Parallel.
+func (a *testAnalysis) sameEffects(other *testAnalysis) bool {
+	return a.hasParallel == other.hasParallel &&
+		a.cantParallel == other.cantParallel &&
+		a.numberOfTestRun == other.numberOfTestRun
+}
+
+// markCantParallel records that the test can't call t.Parallel, keeping the first reason.
+func (a *testAnalysis) markCantParallel(reason string) {
+	if !a.cantParallel {
+		a.cantParallel = true
+		a.cantParallelReason = reason
+	}
 }
 
 func (a *parallelAnalyzer) run(pass *analysis.Pass) (any, error) {
+	if err := validEngine(a.config.Engine); err != nil {
+		return nil, err
+	}
+	// Other packages only see the functions of this one through its facts, so
+	// the cache lives as long as the pass.
+	p := &parallelAnalyzer{
+		config:  a.config,
+		visited: make(map[*ast.BlockStmt]*testAnalysis),
+		decls:   make(map[*types.Func]*ast.FuncDecl),
+		bubbles: make(map[types.Object]bool),
+	}
+	if a.config.Engine == EngineCHA || a.config.Engine == EngineVTA {
+		p.callGraph = &callGraph{pass: pass, engine: a.config.Engine, extraSigs: a.config.ExtraSigs}
+	}
+	// Recursive helpers are walked again once their analysis is complete,
+	// which may report the same diagnostic twice.
+	pass = reportOnce(pass)
+
+	return p.runPass(pass)
+}
+
+func (a *parallelAnalyzer) runPass(pass *analysis.Pass) (any, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
 	nodeFilter := []ast.Node{
 		(*ast.FuncDecl)(nil),
 	}
 
-	inspector.New(pass.Files).Preorder(nodeFilter, func(node ast.Node) {
+	// Index the declarations first, tests may call helpers declared after them.
+	var funcDecls []*ast.FuncDecl
+	inspect.Preorder(nodeFilter, func(node ast.Node) {
 		funcDecl := node.(*ast.FuncDecl)
+		if fn, ok := pass.TypesInfo.Defs[funcDecl.Name].(*types.Func); ok {
+			a.decls[fn] = funcDecl
+		}
+		funcDecls = append(funcDecls, funcDecl)
+	})
+
+	for _, funcDecl := range funcDecls {
 		// Only process _test.go files
-		if !strings.HasSuffix(pass.Fset.File(funcDecl.Pos()).Name(), "_test.go") {
-			return
+		if !isTestFile(pass.Fset.File(funcDecl.Pos()).Name()) {
+			continue
 		}
 
 		// Check runs for test functions only
-		if isTestFunction(funcDecl) {
+		if isTestFunction(pass, funcDecl) {
 			a.analyzeTestFunction(pass, funcDecl)
 		}
-	})
+	}
+
+	a.exportHelperFacts(pass)
 
 	return nil, nil
 }
 
 func (a *parallelAnalyzer) analyzeTestFunction(pass *analysis.Pass, funcDecl *ast.FuncDecl) {
-	analysis := a.analyzeFunction(pass, funcDecl)
+	if findTestParam(pass, funcDecl.Type.Params) == nil {
+		// The test can't refer to its *testing.T, so it can never call t.Parallel().
+		if !a.config.IgnoreMissing {
+			pass.Report(analysis.Diagnostic{
+				Pos:            funcDecl.Pos(),
+				Message:        fmt.Sprintf("Function %s has an unnamed *testing.T parameter and is missing the call to method parallel\n", funcDecl.Name.Name),
+				SuggestedFixes: nameTestParamFix(pass, funcDecl),
+			})
+		}
+		return
+	}
+
+	result := a.analyzeFunction(pass, funcDecl)
+
+	if !a.config.IgnoreMissing && !result.hasParallel && !result.cantParallel {
+		pass.Report(analysis.Diagnostic{
+			Pos:            funcDecl.Pos(),
+			Message:        fmt.Sprintf("Function %s missing the call to method parallel\n", funcDecl.Name.Name),
+			SuggestedFixes: parallelFix(pass, funcDecl.Type, funcDecl.Body),
+		})
+	}
+
+	a.reportDefer(pass, result, funcDecl.Name.Name, funcDecl.Type, funcDecl.Body)
+	a.reportTestCalls(pass, result, funcDecl.Name.Name, funcDecl.Type, funcDecl.Body)
+	a.reportParallelAncestors(pass, result, funcDecl.Name.Name, "", nil, make(map[ancestry]bool))
+}
+
+func (a *parallelAnalyzer) reportDefer(pass *analysis.Pass, result *testAnalysis, name string, funcType *ast.FuncType, body *ast.BlockStmt) {
+	if result.final() && a.config.CheckCleanup && result.hasParallel && result.funcHasDeferStatement && result.numberOfTestRun > 0 {
+		for _, deferStmt := range result.deferStatements {
+			pass.Report(analysis.Diagnostic{
+				Pos:            deferStmt.Pos(),
+				Message:        fmt.Sprintf("Function %s uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n", name),
+				SuggestedFixes: cleanupFix(pass, funcType, body, deferStmt),
+			})
+		}
+	}
+}
 
-	if !a.config.IgnoreMissing && !analysis.hasParallel && !analysis.cantParallel {
-		pass.Reportf(funcDecl.Pos(), "Function %s missing the call to method parallel\n", funcDecl.Name.Name)
+// reportTestCalls reports the misuses of the calls of a test to the methods of its T.
+func (a *parallelAnalyzer) reportTestCalls(pass *analysis.Pass, result *testAnalysis, name string, funcType *ast.FuncType, body *ast.BlockStmt) {
+	testVar := findTestParam(pass, funcType.Params)
+	if !result.final() || testVar == nil {
+		return
+	}
+	a.reportEnvConflict(pass, result, name, testVar)
+	a.reportParallelChain(pass, result, name, testVar)
+	a.reportParallelPlacement(pass, result, name, testVar)
+	if a.config.CheckParallelFirst {
+		a.reportParallelOrder(pass, result, name, testVar, body)
 	}
+	a.reportSubtestResults(pass, result, name, funcType, testVar, body)
+	a.reportDeadlocks(pass, result, name, testVar, body)
+	a.reportRunResults(pass, result, name, testVar, body)
+	reportGoroutines(pass, body)
+}
 
-	a.reportDefer(pass, analysis, funcDecl.Name.Name)
+// reportEnvConflict reports a test that calls t.Parallel and t.Setenv or
+// t.Chdir on its T, in either order. The testing package panics on the second
+// call. Calls in branches that exclude each other are left out, and the test
+// only may panic if either call is conditional.
+func (a *parallelAnalyzer) reportEnvConflict(pass *analysis.Pass, result *testAnalysis, name string, testVar types.Object) {
+	if result.parallelCall == nil || result.envCall == nil || exclusive(pass, result.envCall, result.parallelCall) {
+		return
+	}
+	first, second := result.envCall, result.parallelCall
+	if second.pos < first.pos || (second.pos == first.pos && second.method < first.method) {
+		first, second = second, first
+	}
+	panics := "panics"
+	if first.conditional() || second.conditional() {
+		panics = "may panic"
+	}
+
+	pass.Report(analysis.Diagnostic{
+		Pos: second.pos,
+		Message: fmt.Sprintf("Function %s calls %s after %s, which %s: t.Setenv and t.Chdir can't be used with t.Parallel\n",
+			name, second.describe(testVar.Name()), first.describe(testVar.Name()), panics),
+		Related: []analysis.RelatedInformation{
+			{Pos: first.method, Message: fmt.Sprintf("first call to %s", first.name)},
+			{Pos: second.method, Message: fmt.Sprintf("then call to %s", second.name)},
+		},
+	})
 }
 
-func (a *parallelAnalyzer) reportDefer(pass *analysis.Pass, analysis *testAnalysis, name string) {
-	if a.config.CheckCleanup && analysis.hasParallel && analysis.funcHasDeferStatement && analysis.numberOfTestRun > 0 {
-		for _, deferStmt := range analysis.deferStatements {
-			pass.Reportf(deferStmt.Pos(), "Function %s uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n", name)
+// reportParallelPlacement reports the calls of a test to t.Parallel that don't
+// make it parallel, or that panic because t.Parallel is called more than once.
+// Calls made only under a condition are reported with CheckConditionalParallel.
+func (a *parallelAnalyzer) reportParallelPlacement(pass *analysis.Pass, result *testAnalysis, name string, testVar types.Object) {
+	// once holds the calls that are made at most once.
+	var once []*testCall
+	for _, call := range result.parallelCalls {
+		var format string
+		switch call.placement {
+		case placedInBody:
+			once = append(once, call)
+			continue
+		case placedConditional:
+			once = append(once, call)
+			if !a.config.CheckConditionalParallel {
+				continue
+			}
+			format = "Function %s calls %s only under a condition, the test may not run in parallel\n"
+		case placedInLoop:
+			format = "Function %s calls %s in a loop, which panics: t.Parallel can't be called more than once\n"
+		case placedInCleanup:
+			format = "Function %s calls %s in t.Cleanup, which runs once the test is over and doesn't make it parallel\n"
+		case placedDeferred:
+			format = "Function %s defers %s, which runs once the test is over and doesn't make it parallel\n"
+		case placedInGoroutine:
+			format = "Function %s calls %s in a goroutine, t.Parallel must be called by the test itself\n"
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     call.pos,
+			Message: fmt.Sprintf(format, name, call.describe(testVar.Name())),
+			Related: []analysis.RelatedInformation{{Pos: call.method, Message: "call to Parallel"}},
+		})
+	}
+
+	// The first pair of calls that can both be made is reported, it may only
+	// panic if either call is conditional. A conditional call to the same
+	// t.Parallel twice comes from a recursive helper, which usually makes it
+	// once.
+	for j, second := range once {
+		i := slices.IndexFunc(once[:j], func(first *testCall) bool {
+			if (first.conditional() || second.conditional()) && first.method == second.method {
+				return false
+			}
+			return !exclusive(pass, first, second)
+		})
+		if i < 0 {
+			continue
+		}
+		first := once[i]
+		panics := "panics"
+		if first.conditional() || second.conditional() {
+			panics = "may panic"
 		}
+		pass.Report(analysis.Diagnostic{
+			Pos: second.pos,
+			Message: fmt.Sprintf("Function %s calls %s after %s, which %s: t.Parallel can't be called more than once\n",
+				name, second.describe(testVar.Name()), first.describe(testVar.Name()), panics),
+			Related: []analysis.RelatedInformation{
+				{Pos: first.method, Message: "first call to Parallel"},
+				{Pos: second.method, Message: "then call to Parallel"},
+			},
+		})
+		break
 	}
 }
 
-func (a *parallelAnalyzer) reportParallelSubtest(pass *analysis.Pass, analysis *testAnalysis, node ast.Node, name string) {
-	if !a.config.IgnoreMissing && !a.config.IgnoreMissingSubtests && !analysis.hasParallel && !analysis.cantParallel {
-		pass.Reportf(node.Pos(), "Function %s missing the call to method parallel in the t.Run\n", name)
+// reportParallelChain reports a test that calls t.Parallel while the call graph
+// found a call chain to t.Setenv, t.Chdir or one of the extra signatures. The
+// chains to the calls on the T of the test that the syntax tree shows too are
+// reported by reportEnvConflict.
+func (a *parallelAnalyzer) reportParallelChain(pass *analysis.Pass, result *testAnalysis, name string, testVar types.Object) {
+	if result.chain == "" || result.parallelCall == nil || result.envCall != nil {
+		return
 	}
+	pass.Report(analysis.Diagnostic{
+		Pos: result.parallelCall.pos,
+		Message: fmt.Sprintf("Function %s calls %s but %s, which can't be used with t.Parallel\n",
+			name, result.parallelCall.describe(testVar.Name()), result.chain),
+	})
 }
 
-// analyzeTestRun analyzes the three types of t.Run calls:
+// ancestry is a subtest body together with its closest parallel ancestor.
+type ancestry struct {
+	body     *ast.BlockStmt
+	parallel *testCall
+}
+
+// reportParallelAncestors reports the subtests of the test at path that call
+// t.Setenv or t.Chdir while one of their ancestors runs in parallel. The
+// testing package panics on the call. The test itself runs in parallel once
+// it called t.Parallel before starting the subtest. parallelPath is the path
+// of the outermost parallel ancestor of the test and parallel its call to
+// t.Parallel, they are empty if there is none.
+func (a *parallelAnalyzer) reportParallelAncestors(pass *analysis.Pass, result *testAnalysis, path, parallelPath string, parallel *testCall, seen map[ancestry]bool) {
+	for _, sub := range result.subtests {
+		subParallelPath, subParallel := parallelPath, parallel
+		if subParallel == nil && result.parallelCall != nil && result.parallelCall.pos < sub.run {
+			subParallelPath, subParallel = path, result.parallelCall
+		}
+		child := a.visited[sub.body]
+		if child == nil || seen[ancestry{sub.body, subParallel}] {
+			continue
+		}
+		seen[ancestry{sub.body, subParallel}] = true
+
+		subPath := path + "/" + sub.name
+		if testVar := findTestParam(pass, sub.funcType.Params); subParallel != nil && child.envCall != nil && testVar != nil {
+			pass.Report(analysis.Diagnostic{
+				Pos: child.envCall.pos,
+				Message: fmt.Sprintf("Function %s calls %s under the parallel test %s, which panics: t.Setenv and t.Chdir can't be used in subtests of parallel tests\n",
+					subPath, child.envCall.describe(testVar.Name()), subParallelPath),
+				Related: []analysis.RelatedInformation{
+					{Pos: subParallel.method, Message: fmt.Sprintf("%s calls Parallel", subParallelPath)},
+					{Pos: child.envCall.method, Message: fmt.Sprintf("then %s calls %s", subPath, child.envCall.name)},
+				},
+			})
+		}
+		a.reportParallelAncestors(pass, child, subPath, subParallelPath, subParallel, seen)
+	}
+}
+
+func (a *parallelAnalyzer) reportParallelSubtest(pass *analysis.Pass, result *testAnalysis, node ast.Node, name string, fixes []analysis.SuggestedFix) {
+	if result.final() && !a.config.IgnoreMissing && !a.config.IgnoreMissingSubtests && !result.hasParallel && !result.cantParallel {
+		pass.Report(analysis.Diagnostic{
+			Pos:            node.Pos(),
+			Message:        fmt.Sprintf("Function %s missing the call to method parallel in the t.Run\n", name),
+			SuggestedFixes: fixes,
+		})
+	}
+}
+
+// analyzeTestRun analyzes the four types of t.Run calls:
 // 1. Inline function: t.Run("name", new func(t *testing.T) {...})
-// 2. Direct function identifier: t.Run("name", myFunc)
+// 2. Direct function or method value: t.Run("name", myFunc) or t.Run("name", s.myMethod)
 // 3. Builder function: t.Run("name", builder(t))
-func (a *parallelAnalyzer) analyzeTestRun(pass *analysis.Pass, callExpr *ast.CallExpr, testVar string) *testAnalysis {
-	if isTestRunCall(callExpr, testVar) && len(callExpr.Args) > 1 {
-		if funcLit, ok := callExpr.Args[1].(*ast.FuncLit); ok {
+// 4. Function variable or field: t.Run("name", run) or t.Run(tc.name, tc.test)
+func (a *parallelAnalyzer) analyzeTestRun(pass *analysis.Pass, callExpr *ast.CallExpr, testVar types.Object) *testAnalysis {
+	if args := methodArgs(pass, callExpr); isTestRunCall(pass, callExpr, testVar) && len(args) > 1 {
+		name := subtestName(pass, args[0])
+		if funcLit, ok := args[1].(*ast.FuncLit); ok {
 			// Case 1: Inline function: t.Run("name", new func(t *testing.T) {...})
-			analysis := a.analyzeFuncLit(pass, funcLit)
+			// The cached analysis is copied, the subtest count belongs to the caller.
+			analysis := *a.analyzeFuncLit(pass, funcLit)
 
-			a.reportDefer(pass, analysis, "literal")
-			a.reportParallelSubtest(pass, analysis, funcLit, "literal")
+			a.reportDefer(pass, &analysis, "literal", funcLit.Type, funcLit.Body)
+			a.reportTestCalls(pass, &analysis, "literal", funcLit.Type, funcLit.Body)
+			a.reportParallelSubtest(pass, &analysis, funcLit, "literal", parallelFix(pass, funcLit.Type, funcLit.Body))
+			reportParentT(pass, funcLit, name)
 			analysis.numberOfTestRun++
+			analysis.subtests = []subtest{{name, callExpr.Pos(), funcLit.Type, funcLit.Body}}
 
-			return analysis
-		} else if ident, ok := callExpr.Args[1].(*ast.Ident); ok {
-			// Case 2: Direct function identifier: t.Run("name", myFunc)
-			funcDecl := findFunction(pass, ident.Name)
+			return &analysis
+		} else if fn := referencedFunc(pass, args[1]); fn != nil {
+			// Case 2: Direct function or method value: t.Run("name", myFunc)
+			funcDecl := a.findFunction(fn)
 			if funcDecl != nil && hasExactlyOneParameter(funcDecl) {
-				analysis := a.analyzeFunction(pass, funcDecl)
+				analysis := *a.analyzeFunction(pass, funcDecl)
+
+				a.reportDefer(pass, &analysis, fn.Name(), funcDecl.Type, funcDecl.Body)
+				a.reportTestCalls(pass, &analysis, fn.Name(), funcDecl.Type, funcDecl.Body)
+				a.reportParallelSubtest(pass, &analysis, callExpr, fn.Name(), parallelFix(pass, funcDecl.Type, funcDecl.Body))
+				analysis.numberOfTestRun++
+				analysis.subtests = []subtest{{name, callExpr.Pos(), funcDecl.Type, funcDecl.Body}}
 
-				a.reportDefer(pass, analysis, ident.Name)
-				a.reportParallelSubtest(pass, analysis, callExpr, ident.Name)
+				return &analysis
+			} else if analysis := importedAnalysis(pass, fn); analysis != nil {
+				// The function is declared in another package, use its fact instead.
+				a.reportParallelSubtest(pass, analysis, callExpr, fn.Name(), nil)
 				analysis.numberOfTestRun++
 
 				return analysis
 			}
-		} else if builderCall, ok := callExpr.Args[1].(*ast.CallExpr); ok {
+		} else if builderCall, ok := args[1].(*ast.CallExpr); ok {
 			// Case 3: Builder function: t.Run("name", builder(t))
-			funcName := getCallName(builderCall)
-			funcDecl := findFunction(pass, funcName)
+			fn := calleeFunc(pass, builderCall)
+			funcDecl := a.findFunction(fn)
 
 			if funcDecl != nil {
-				parentAnalysis, builderAnalysis := a.analyzeBuilderCall(pass, funcDecl)
+				funcName := fn.Name()
+				parentAnalysis, builderAnalysis, funcLits := a.analyzeBuilderCall(pass, funcDecl)
+
+				// Only the returned literals that are missing the call need fixing.
+				var fixes []analysis.SuggestedFix
+				var subtests []subtest
+				for _, funcLit := range funcLits {
+					litAnalysis := a.analyzeFuncLit(pass, funcLit)
+					if !litAnalysis.hasParallel && !litAnalysis.cantParallel {
+						fixes = append(fixes, parallelFix(pass, funcLit.Type, funcLit.Body)...)
+					}
+					a.reportDefer(pass, litAnalysis, funcName, funcLit.Type, funcLit.Body)
+					a.reportTestCalls(pass, litAnalysis, funcName, funcLit.Type, funcLit.Body)
+					reportParentT(pass, funcLit, name)
+					subtests = append(subtests, subtest{name, callExpr.Pos(), funcLit.Type, funcLit.Body})
+				}
 
-				a.reportDefer(pass, builderAnalysis, funcName)
-				a.reportParallelSubtest(pass, builderAnalysis, callExpr, funcName)
+				a.reportParallelSubtest(pass, builderAnalysis, callExpr, funcName, fixes)
 				parentAnalysis.merge(builderAnalysis)
 				parentAnalysis.numberOfTestRun++
+				// The builder runs with the T of the parent, when t.Run is called.
+				for i := range parentAnalysis.subtests {
+					parentAnalysis.subtests[i].run = callExpr.Pos()
+				}
+				parentAnalysis.subtests = append(parentAnalysis.subtests, subtests...)
 
 				return parentAnalysis
 			}
+		} else if values := funcValues(pass, args[1]); len(values) > 0 {
+			// Case 4: Function variable or field: t.Run("name", run) or t.Run(tc.name, tc.test)
+			// Each function assigned to the variable or field is a subtest body.
+			analysis := &testAnalysis{}
+			for _, value := range values {
+				valueAnalysis, funcType, body := a.analyzeFuncValue(pass, value, name)
+				analysis.merge(valueAnalysis)
+				if body != nil {
+					analysis.subtests = append(analysis.subtests, subtest{name, callExpr.Pos(), funcType, body})
+				}
+			}
+			analysis.numberOfTestRun++
+
+			return analysis
 		}
+
+		// The subtest function can't be resolved, but it is a subtest nevertheless.
+		return &testAnalysis{numberOfTestRun: 1}
 	}
 
 	return &testAnalysis{}
 }
 
+// analyzeFuncValue analyzes a function literal or function reference that is
+// assigned to a variable or field passed to t.Run, for the subtest with the
+// given name. Diagnostics point at the value. The function type and body of
+// the subtest are nil if it can't be resolved.
+func (a *parallelAnalyzer) analyzeFuncValue(pass *analysis.Pass, value ast.Expr, name string) (*testAnalysis, *ast.FuncType, *ast.BlockStmt) {
+	if funcLit, ok := value.(*ast.FuncLit); ok {
+		analysis := a.analyzeFuncLit(pass, funcLit)
+
+		a.reportDefer(pass, analysis, "literal", funcLit.Type, funcLit.Body)
+		a.reportTestCalls(pass, analysis, "literal", funcLit.Type, funcLit.Body)
+		a.reportParallelSubtest(pass, analysis, funcLit, "literal", parallelFix(pass, funcLit.Type, funcLit.Body))
+		reportParentT(pass, funcLit, name)
+
+		return analysis, funcLit.Type, funcLit.Body
+	}
+
+	fn := referencedFunc(pass, value)
+	funcDecl := a.findFunction(fn)
+	if funcDecl == nil || !hasExactlyOneParameter(funcDecl) {
+		return &testAnalysis{}, nil, nil
+	}
+	analysis := a.analyzeFunction(pass, funcDecl)
+
+	a.reportDefer(pass, analysis, fn.Name(), funcDecl.Type, funcDecl.Body)
+	a.reportTestCalls(pass, analysis, fn.Name(), funcDecl.Type, funcDecl.Body)
+	a.reportParallelSubtest(pass, analysis, value, fn.Name(), parallelFix(pass, funcDecl.Type, funcDecl.Body))
+
+	return analysis, funcDecl.Type, funcDecl.Body
+}
+
 func (a *parallelAnalyzer) analyzeFunctionCall(pass *analysis.Pass, callExpr *ast.CallExpr) *testAnalysis {
-	funcName := getCallName(callExpr)
-	funcDecl := findFunction(pass, funcName)
+	fn := calleeFunc(pass, callExpr)
+	funcDecl := a.findFunction(fn)
 	if funcDecl == nil {
+		if analysis := importedAnalysis(pass, fn); analysis != nil {
+			return analysis
+		}
 		return &testAnalysis{}
 	}
 
 	return a.analyzeFunction(pass, funcDecl)
 }
 
-func (a *parallelAnalyzer) visitExprStmt(pass *analysis.Pass, analysis *testAnalysis, testVar string) func(n ast.Node) bool {
+func (a *parallelAnalyzer) visitExprStmt(pass *analysis.Pass, analysis *testAnalysis, testVar types.Object) func(n ast.Node) bool {
 	return func(n ast.Node) bool {
 		if callExpr, ok := n.(*ast.CallExpr); ok {
 			a.analyzeCallExpr(pass, analysis, testVar, callExpr)
@@ -201,27 +680,81 @@ func (a *parallelAnalyzer) visitExprStmt(pass *analysis.Pass, analysis *testAnal
 	}
 }
 
-func (a *parallelAnalyzer) analyzeCallExpr(pass *analysis.Pass, analysis *testAnalysis, testVar string, callExpr *ast.CallExpr) {
-	// Edge case, check each parameter of the call to analyze.
+func (a *parallelAnalyzer) analyzeCallExpr(pass *analysis.Pass, analysis *testAnalysis, testVar types.Object, callExpr *ast.CallExpr) {
+	// Walk the callee and each argument, so that nested calls and function
+	// literals that are invoked inline or passed along, such as
+	// withLock(func() { t.Setenv(...) }) or t.Cleanup(func() {...}), are
+	// analyzed as part of the body. The function literal of a t.Run call is a
+	// subtest of its own and is analyzed by analyzeTestRun instead, the one of
+	// a synctest.Test call runs in a bubble and is analyzed by analyzeBubble.
+	var subtest ast.Expr
+	if args := methodArgs(pass, callExpr); isTestRunCall(pass, callExpr, testVar) && len(args) > 1 {
+		if _, ok := args[1].(*ast.FuncLit); ok {
+			subtest = args[1]
+		}
+	}
+	if isSynctestTest(pass, callExpr) && len(callExpr.Args) == 2 {
+		subtest = callExpr.Args[1]
+	}
+	ast.Inspect(callExpr.Fun, a.visitExprStmt(pass, analysis, testVar))
 	for _, arg := range callExpr.Args {
-		if nestedCallExpr, ok := arg.(*ast.CallExpr); ok {
-			a.analyzeCallExpr(pass, analysis, testVar, nestedCallExpr)
+		if arg != subtest {
+			ast.Inspect(arg, a.visitExprStmt(pass, analysis, testVar))
 		}
 	}
 
-	analysis.hasParallel = analysis.hasParallel || isParallelCall(callExpr, testVar)
-	analysis.cantParallel = analysis.cantParallel || isSetenvCall(callExpr, testVar)
-	analysis.cantParallel = analysis.cantParallel || isChdirCall(callExpr, testVar)
+	if isParallelCall(pass, callExpr, testVar) {
+		analysis.hasParallel = true
+		call := methodCall(callExpr, "Parallel")
+		if analysis.parallelCall == nil {
+			analysis.parallelCall = call
+		}
+		analysis.parallelCalls = append(analysis.parallelCalls, call)
+	}
+	if isSetenvCall(pass, callExpr, testVar) || isChdirCall(pass, callExpr, testVar) {
+		fn := calleeFunc(pass, callExpr)
+		analysis.markCantParallel(callReason(fn))
+		if analysis.envCall == nil {
+			analysis.envCall = methodCall(callExpr, fn.Name())
+		}
+	}
 	if fnIdent, ok := callExpr.Fun.(*ast.SelectorExpr); ok {
-		signature := pass.TypesInfo.ObjectOf(fnIdent.Sel).String()
-		analysis.cantParallel = analysis.cantParallel || contains(a.config.ExtraSigs, signature)
+		obj := pass.TypesInfo.ObjectOf(fnIdent.Sel)
+		if contains(a.config.ExtraSigs, obj.String()) {
+			analysis.markCantParallel(callReason(obj))
+		}
 	}
 	if fnIdent, ok := callExpr.Fun.(*ast.Ident); ok {
-		signature := pass.TypesInfo.ObjectOf(fnIdent).String()
-		analysis.cantParallel = analysis.cantParallel || contains(a.config.ExtraSigs, signature)
+		obj := pass.TypesInfo.ObjectOf(fnIdent)
+		if contains(a.config.ExtraSigs, obj.String()) {
+			analysis.markCantParallel(callReason(obj))
+		}
+	}
+	runPass := pass
+	if a.bubbles[testVar] {
+		// The subtest can't be started in a bubble, the call to t.Run is
+		// reported instead.
+		runPass = quietPass(pass)
+	}
+	run := a.analyzeTestRun(runPass, callExpr, testVar)
+	analysis.merge(run)
+	analysis.subtests = append(analysis.subtests, run.subtests...)
+	if a.config.CheckParallelFirst {
+		if call := orderCall(pass, callExpr, testVar); call != nil {
+			analysis.orderCalls = append(analysis.orderCalls, call)
+		}
+	}
+	if isSynctestTest(pass, callExpr) {
+		// The bubble doesn't make the test parallel, but its T is a child of
+		// the test's T, which can't be parallel if the bubble calls t.Setenv.
+		bubble := a.analyzeBubble(pass, callExpr)
+		analysis.merge(&testAnalysis{cantParallel: bubble.cantParallel, cantParallelReason: bubble.cantParallelReason, pending: bubble.pending})
+	}
+	helper := a.analyzeFunctionCall(pass, callExpr)
+	analysis.merge(helper)
+	if fn := calleeFunc(pass, callExpr); fn != nil {
+		analysis.recordHelperCalls(helper, callExpr, fn.Name())
 	}
-	analysis.merge(a.analyzeTestRun(pass, callExpr, testVar))
-	analysis.merge(a.analyzeFunctionCall(pass, callExpr))
 }
 
 func (a *parallelAnalyzer) analyzeFuncLit(pass *analysis.Pass, funcLit *ast.FuncLit) *testAnalysis {
@@ -232,17 +765,83 @@ func (a *parallelAnalyzer) analyzeFunction(pass *analysis.Pass, funcDecl *ast.Fu
 	return a.analyzeFunctionF(pass, funcDecl.Type, funcDecl.Body)
 }
 
+// analyzeFunctionF analyzes the body of a function that takes a *testing.T.
+// Helpers may call themselves, directly or through other helpers. A recursive
+// call sees the analysis found so far, and once the walk is complete the
+// function is walked again with its full analysis until nothing new is found.
+// Diagnostics that depend on a partial analysis wait for the final walk.
+//
+// A partial analysis is reused until a recursive function is walked again,
+// which starts a new round. Otherwise each call to a function of a cycle would
+// walk it again while the cycle is in progress, which takes exponential time
+// when the functions call each other more than once.
 func (a *parallelAnalyzer) analyzeFunctionF(pass *analysis.Pass, funcType *ast.FuncType, body *ast.BlockStmt) *testAnalysis {
-	analysis := &testAnalysis{}
-
-	testVar := findTestParamName(funcType.Params)
-	if testVar == "" {
-		return analysis
+	testVar := findTestParam(pass, funcType.Params)
+	if testVar == nil || body == nil {
+		// Functions implemented in assembly or with go:linkname have no body.
+		return &testAnalysis{}
+	}
+	v := a.visited[body]
+	if v != nil && v.inProgress {
+		// The subtests of the recursive call are the ones being counted already.
+		partial := *v
+		partial.inProgress = false
+		partial.numberOfTestRun = 0
+		partial.pending = maps.Clone(v.pending)
+		if partial.pending == nil {
+			partial.pending = make(map[*ast.BlockStmt]bool)
+		}
+		partial.pending[body] = true
+		return &partial
 	}
-	hash, v := a.getAnalysis(body)
-	if v != nil {
+	if v != nil && (len(v.pending) == 0 || v.round == a.round) {
 		return v
 	}
+	// The function is analyzed for the first time, or its analysis depended on
+	// a function that was still in progress then and may be complete now.
+
+	analysis := &testAnalysis{inProgress: true, round: a.round}
+	a.visited[body] = analysis
+	a.walkFunction(pass, analysis, testVar, body)
+	if !analysis.pending[body] {
+		analysis.inProgress = false
+		return analysis
+	}
+
+	for {
+		a.round++
+		next := &testAnalysis{inProgress: true, round: a.round}
+		a.walkFunction(quietPass(pass), next, testVar, body)
+		converged := next.sameEffects(analysis)
+		analysis = next
+		a.visited[body] = analysis
+		if converged {
+			break
+		}
+	}
+	delete(analysis.pending, body)
+	analysis.inProgress = false
+
+	if len(analysis.pending) == 0 {
+		// Recursive calls see the final analysis now, report the diagnostics
+		// that waited for it.
+		a.round++
+		a.walkFunction(pass, &testAnalysis{}, testVar, body)
+	}
+
+	return analysis
+}
+
+// walkFunction merges the calls of the body into the analysis.
+func (a *parallelAnalyzer) walkFunction(pass *analysis.Pass, analysis *testAnalysis, testVar types.Object, body *ast.BlockStmt) {
+	if a.callGraph != nil {
+		// The call graph also finds the calls made through interfaces,
+		// function values and closures. It goes first, so that the
+		// reason names the call chain it found.
+		reached := a.callGraph.reach(body)
+		analysis.merge(reached)
+		analysis.chain = reached.cantParallelReason
+	}
 
 	for _, l := range body.List {
 		switch v := l.(type) {
@@ -251,29 +850,33 @@ func (a *parallelAnalyzer) analyzeFunctionF(pass *analysis.Pass, funcType *ast.F
 				analysis.funcHasDeferStatement = true
 				analysis.deferStatements = append(analysis.deferStatements, v)
 			}
+			ast.Inspect(v, a.visitExprStmt(pass, analysis, testVar))
 		default:
 			ast.Inspect(v, a.visitExprStmt(pass, analysis, testVar))
 		}
 	}
-
-	a.cacheAnalysis(hash, analysis)
-
-	return analysis
+	calls := slices.Concat(analysis.parallelCalls, analysis.orderCalls)
+	if analysis.envCall != nil {
+		calls = append(calls, analysis.envCall)
+	}
+	placeCalls(pass, calls, testVar, body)
 }
 
 // analyzeBuilderCall analyzes a function call that returns a test function
 // to see if the returned function contains t.Parallel()
-func (a *parallelAnalyzer) analyzeBuilderCall(pass *analysis.Pass, funcDecl *ast.FuncDecl) (*testAnalysis, *testAnalysis) {
+// It also returns the function literals the builder returns.
+func (a *parallelAnalyzer) analyzeBuilderCall(pass *analysis.Pass, funcDecl *ast.FuncDecl) (*testAnalysis, *testAnalysis, []*ast.FuncLit) {
 	parentAnalysis := &testAnalysis{}
 	builderAnalysis := &testAnalysis{}
-	testVar := findTestParamName(funcDecl.Type.Params)
+	var funcLits []*ast.FuncLit
+	testVar := findTestParam(pass, funcDecl.Type.Params)
 
 	// Found the builder function, analyze it and return immediately
 	ast.Inspect(funcDecl, func(n ast.Node) bool {
 		switch v := n.(type) {
 		case *ast.ExprStmt, *ast.RangeStmt:
 			// We only need to analyze if we have a test variable to actually check for t.Parallel()
-			if testVar != "" {
+			if testVar != nil {
 				ast.Inspect(v, a.visitExprStmt(pass, parentAnalysis, testVar))
 			}
 		case *ast.ReturnStmt:
@@ -282,12 +885,13 @@ func (a *parallelAnalyzer) analyzeBuilderCall(pass *analysis.Pass, funcDecl *ast
 				if funcLit, ok := result.(*ast.FuncLit); ok {
 					innerAnalysis := a.analyzeFuncLit(pass, funcLit)
 					builderAnalysis.merge(innerAnalysis)
+					funcLits = append(funcLits, funcLit)
 				}
 			}
 		}
 		return true
 	})
-	return parentAnalysis, builderAnalysis
+	return parentAnalysis, builderAnalysis, funcLits
 }
 
 func contains(slice []string, el string) bool {
@@ -36,7 +36,7 @@ func TestCheckCleanupOption(t *testing.T) {
 
 	analyzer := NewAnalyzer(Config{CheckCleanup: true})
 
-	analysistest.Run(t, analysistest.TestData(), analyzer, "cleanup")
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), analyzer, "cleanup")
 }
 
 func TestExtraSigsOptions(t *testing.T) {
@@ -46,3 +46,177 @@ func TestExtraSigsOptions(t *testing.T) {
 
 	analysistest.Run(t, analysistest.TestData(), analyzer, "skip")
 }
+
+func TestSuggestedFixes(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), analyzer, "fix")
+}
+
+func TestTypedTestingT(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "typed")
+}
+
+func TestDiscovery(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "discovery")
+}
+
+func TestResolveHelpers(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "resolve")
+}
+
+func TestGenerics(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "generics")
+}
+
+func TestClosures(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "closures")
+}
+
+func TestFunctionValues(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "table")
+}
+
+func TestHelperFacts(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "facts", "facts/testutil")
+}
+
+func TestCallGraphEngines(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range []string{EngineCHA, EngineVTA} {
+		t.Run(engine, func(t *testing.T) {
+			t.Parallel()
+
+			analyzer := NewAnalyzer(Config{Engine: engine})
+
+			analysistest.Run(t, analysistest.TestData(), analyzer, "callgraph")
+		})
+	}
+}
+
+func TestVTAEngine(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{Engine: EngineVTA})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "callgraphvta")
+}
+
+func TestRecursiveHelpers(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "recursive")
+}
+
+func TestEnvConflicts(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "envconflict")
+}
+
+func TestParallelAncestors(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "ancestors")
+}
+
+func TestParallelPlacement(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "placement")
+}
+
+func TestConditionalParallel(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{CheckConditionalParallel: true})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "conditional")
+}
+
+func TestParallelOrder(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{CheckParallelFirst: true})
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), analyzer, "order")
+}
+
+func TestParentTInSubtests(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), analyzer, "parent")
+}
+
+func TestGoroutines(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "goroutines")
+}
+
+func TestSubtestResults(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), analyzer, "results")
+}
+
+func TestDeadlocks(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "deadlock")
+}
+
+func TestSynctestBubbles(t *testing.T) {
+	t.Parallel()
+
+	analyzer := NewAnalyzer(Config{})
+
+	analysistest.Run(t, analysistest.TestData(), analyzer, "bubble")
+}
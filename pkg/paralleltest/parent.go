@@ -0,0 +1,63 @@
+package paralleltest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// reportParentT reports the references of a subtest literal to the T of an
+// enclosing test, directly or passed to helpers such as require.NoError(t, err).
+// The failures are attributed to the enclosing test, and once the subtest runs
+// in parallel the enclosing test may have returned, which makes them panic.
+// The literals of nested subtests are reported on their own.
+func reportParentT(pass *analysis.Pass, funcLit *ast.FuncLit, name string) {
+	param := findTestParam(pass, funcLit.Type.Params)
+	nested := make(map[*ast.FuncLit]bool)
+	ast.Inspect(funcLit.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return !nested[n]
+		case *ast.CallExpr:
+			if fn := calleeFunc(pass, n); fn != nil && fn.Name() == "Run" && isTestingObject(fn) && fn.Signature().Recv() != nil {
+				if args := methodArgs(pass, n); len(args) > 1 {
+					if lit, ok := args[1].(*ast.FuncLit); ok {
+						nested[lit] = true
+					}
+				}
+			}
+		case *ast.Ident:
+			v, ok := pass.TypesInfo.Uses[n].(*types.Var)
+			if !ok || v.IsField() || v.Parent() == pass.Pkg.Scope() || !isTestingTB(pass, v.Type()) ||
+				(funcLit.Pos() <= v.Pos() && v.Pos() < funcLit.End()) {
+				return true
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos: n.Pos(),
+				End: n.End(),
+				Message: fmt.Sprintf("Function literal of the subtest %s uses %s, the T of an enclosing test, which reports to the wrong test and may panic\n",
+					name, n.Name),
+				SuggestedFixes: subtestTFix(pass, n, param),
+			})
+		}
+		return true
+	})
+}
+
+// subtestTFix returns a suggested fix that replaces the reference to the T of
+// an enclosing test with the parameter of the subtest. No fix is returned if
+// the parameter is unnamed or shadowed at the reference.
+func subtestTFix(pass *analysis.Pass, ident *ast.Ident, param types.Object) []analysis.SuggestedFix {
+	if param == nil {
+		return nil
+	}
+	if _, obj := pass.Pkg.Scope().Innermost(ident.Pos()).LookupParent(param.Name(), ident.Pos()); obj != param {
+		return nil
+	}
+	return []analysis.SuggestedFix{{
+		Message:   fmt.Sprintf("Replace %s with %s", ident.Name, param.Name()),
+		TextEdits: []analysis.TextEdit{{Pos: ident.Pos(), End: ident.End(), NewText: []byte(param.Name())}},
+	}}
+}
@@ -0,0 +1,209 @@
+package paralleltest
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// placement is where a function calls a method of its T, from the least to the
+// most misplaced.
+type placement int
+
+const (
+	// placedInBody is a call made once by the function itself.
+	placedInBody placement = iota
+	// placedConditional is a call made only under a condition.
+	placedConditional
+	// placedInLoop is a call that may be made more than once.
+	placedInLoop
+	// placedInCleanup is a call made by a function registered with t.Cleanup.
+	placedInCleanup
+	// placedDeferred is a call made by a deferred function.
+	placedDeferred
+	// placedInGoroutine is a call made by another goroutine.
+	placedInGoroutine
+)
+
+// placeCalls sets the placement of the calls found in the body of a function.
+// A call through a helper is as misplaced as the call in the helper, or as the
+// call of the helper if that is worse.
+func placeCalls(pass *analysis.Pass, calls []*testCall, testVar types.Object, body *ast.BlockStmt) {
+	if len(calls) == 0 {
+		return
+	}
+	placements := make(map[*ast.CallExpr]placement, len(calls))
+	for _, call := range calls {
+		placements[call.call] = placedInBody
+	}
+
+	// Each node pushes the placement of its children, nil pops it.
+	stack := []placement{placedInBody}
+	place := func(p placement) {
+		stack = append(stack, max(stack[len(stack)-1], p))
+	}
+	// children holds the placement of some children of the nodes being walked.
+	children := make(map[ast.Node]placement)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		p, ok := children[n]
+		if !ok {
+			p = placedInBody
+		}
+		place(p)
+
+		switch n := n.(type) {
+		case *ast.IfStmt:
+			children[n.Body] = placedConditional
+			if n.Else != nil {
+				children[n.Else] = placedConditional
+			}
+		case *ast.CaseClause, *ast.CommClause:
+			stack[len(stack)-1] = max(stack[len(stack)-1], placedConditional)
+		case *ast.ForStmt:
+			if n.Cond != nil {
+				children[n.Cond] = placedInLoop
+			}
+			if n.Post != nil {
+				children[n.Post] = placedInLoop
+			}
+			children[n.Body] = placedInLoop
+		case *ast.RangeStmt:
+			children[n.Body] = placedInLoop
+		case *ast.DeferStmt:
+			stack[len(stack)-1] = max(stack[len(stack)-1], placedDeferred)
+		case *ast.GoStmt:
+			stack[len(stack)-1] = max(stack[len(stack)-1], placedInGoroutine)
+		case *ast.CallExpr:
+			if _, ok := placements[n]; ok {
+				placements[n] = stack[len(stack)-1]
+			}
+			if exprCallHasMethod(pass, n, testVar, "Cleanup") {
+				for _, arg := range methodArgs(pass, n) {
+					children[arg] = placedInCleanup
+				}
+			}
+		}
+		return true
+	})
+
+	for _, call := range calls {
+		call.placement = max(call.placement, placements[call.call])
+	}
+}
+
+// conditional reports whether the call may not be made, because it is made
+// under a condition or in a loop.
+func (c *testCall) conditional() bool {
+	return c.placement == placedConditional || c.placement == placedInLoop
+}
+
+// exclusive reports whether two calls of a function can't both be made: they
+// are in different branches of an if or switch statement that isn't in a loop,
+// or one is in an if statement that returns and the other follows it. Calls
+// made through the same call to a helper are compared in the helper.
+func exclusive(pass *analysis.Pass, first, second *testCall) bool {
+	x, y := first.pos, second.pos
+	if x == y {
+		x, y = first.method, second.method
+	}
+	if x == y || !x.IsValid() || !y.IsValid() {
+		return false
+	}
+	contains := func(n ast.Node, pos token.Pos) bool {
+		return n != nil && n.Pos() <= pos && pos < n.End()
+	}
+
+	// ancestors holds the nodes containing both calls, from the innermost
+	// function containing them inwards.
+	var ancestors []ast.Node
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if !contains(n, x) || !contains(n, y) {
+				return false
+			}
+			switch n.(type) {
+			case *ast.FuncDecl, *ast.FuncLit:
+				ancestors = nil
+			}
+			ancestors = append(ancestors, n)
+			return true
+		})
+	}
+
+	inLoop := false
+	for _, n := range ancestors {
+		switch n := n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			inLoop = true
+		case *ast.IfStmt:
+			if !inLoop && (contains(n.Body, x) && contains(n.Else, y) || contains(n.Body, y) && contains(n.Else, x)) {
+				return true
+			}
+		case *ast.SwitchStmt:
+			if !inLoop && differentClauses(n.Body, x, y) {
+				return true
+			}
+		case *ast.TypeSwitchStmt:
+			if !inLoop && differentClauses(n.Body, x, y) {
+				return true
+			}
+		case *ast.SelectStmt:
+			if !inLoop && differentClauses(n.Body, x, y) {
+				return true
+			}
+		case *ast.BlockStmt:
+			if returnsBefore(n, x, y) || returnsBefore(n, y, x) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// differentClauses reports whether the positions are in different clauses of
+// a switch or select statement, the first of which doesn't fall through.
+func differentClauses(body *ast.BlockStmt, x, y token.Pos) bool {
+	clause := func(pos token.Pos) int {
+		for i, stmt := range body.List {
+			if stmt.Pos() <= pos && pos < stmt.End() {
+				return i
+			}
+		}
+		return -1
+	}
+	i, j := clause(x), clause(y)
+	if i < 0 || j < 0 || i == j {
+		return false
+	}
+	if caseClause, ok := body.List[min(i, j)].(*ast.CaseClause); ok && len(caseClause.Body) > 0 {
+		if branch, ok := caseClause.Body[len(caseClause.Body)-1].(*ast.BranchStmt); ok && branch.Tok == token.FALLTHROUGH {
+			return false
+		}
+	}
+	return true
+}
+
+// returnsBefore reports whether the position x is in the body of an if
+// statement of the block that ends with a return, and y follows the if
+// statement in the block.
+func returnsBefore(block *ast.BlockStmt, x, y token.Pos) bool {
+	for _, stmt := range block.List {
+		ifStmt, ok := stmt.(*ast.IfStmt)
+		if !ok || x < ifStmt.Body.Pos() || ifStmt.Body.End() <= x {
+			continue
+		}
+		list := ifStmt.Body.List
+		if len(list) == 0 {
+			return false
+		}
+		_, returns := list[len(list)-1].(*ast.ReturnStmt)
+		return returns && ifStmt.End() <= y
+	}
+	return false
+}
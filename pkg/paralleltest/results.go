@@ -0,0 +1,312 @@
+package paralleltest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// reportSubtestResults reports the statements of a test that read variables
+// written by its parallel subtests after starting them. t.Run returns as soon
+// as a subtest calls t.Parallel, and the parallel subtests only run once the
+// test returns, so the statements read the variables before the subtests write
+// them. Reads in t.Cleanup functions, which run after the subtests, and in
+// subtests are left out, as are receives, which reportDeadlocks reports.
+func (a *parallelAnalyzer) reportSubtestResults(pass *analysis.Pass, result *testAnalysis, name string, funcType *ast.FuncType, testVar types.Object, body *ast.BlockStmt) {
+	// written maps the variables written by the parallel subtests of the
+	// function to the first write.
+	written := make(map[types.Object]*ast.Ident)
+	parallel := a.parallelLiterals(result, body)
+	for _, sub := range parallel {
+		for obj, ident := range writtenVars(pass, sub.body, a.visited[sub.body].parallelCall) {
+			if _, ok := written[obj]; !ok {
+				written[obj] = ident
+			}
+		}
+	}
+	if len(written) == 0 {
+		return
+	}
+
+	skip := make(map[*ast.BlockStmt]bool)
+	for _, sub := range result.subtests {
+		skip[sub.body] = true
+	}
+	reported := make(map[types.Object]bool)
+	receives := make(map[*ast.Ident]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return !skip[n.Body]
+		case *ast.CallExpr:
+			if exprCallHasMethod(pass, n, testVar, "Cleanup") {
+				for _, arg := range methodArgs(pass, n) {
+					if lit, ok := arg.(*ast.FuncLit); ok {
+						skip[lit.Body] = true
+					}
+				}
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				receives[baseIdent(n.X)] = true
+			}
+		case *ast.RangeStmt:
+			if isChan(pass, n.X) {
+				receives[baseIdent(n.X)] = true
+			}
+		case *ast.Ident:
+			if receives[n] {
+				return true
+			}
+			obj := pass.TypesInfo.Uses[n]
+			write, ok := written[obj]
+			if !ok || reported[obj] {
+				return true
+			}
+			var started *subtest
+			for i, sub := range parallel {
+				if sub.body.End() < n.Pos() {
+					started = &parallel[i]
+				}
+			}
+			if started == nil || isAssigned(body, n) {
+				return true
+			}
+			reported[obj] = true
+			pass.Report(analysis.Diagnostic{
+				Pos: n.Pos(),
+				End: n.End(),
+				Message: fmt.Sprintf("Function %s reads %s before its parallel subtests write it, they only run once %s returns: "+
+					"wrap the subtests in %s.Run(\"group\", ...) and read %s after it\n", name, n.Name, name, testVar.Name(), n.Name),
+				Related:        []analysis.RelatedInformation{{Pos: write.Pos(), Message: fmt.Sprintf("%s is written in the subtest %s", n.Name, started.name)}},
+				SuggestedFixes: groupFix(pass, funcType, body, testVar, parallel, n),
+			})
+		}
+		return true
+	})
+}
+
+// parallelLiterals returns the subtests that the function starts with
+// literals of its body and that call t.Parallel.
+func (a *parallelAnalyzer) parallelLiterals(result *testAnalysis, body *ast.BlockStmt) []subtest {
+	var parallel []subtest
+	for _, sub := range result.subtests {
+		child := a.visited[sub.body]
+		if child != nil && child.parallelCall != nil && body.Pos() <= sub.body.Pos() && sub.body.End() <= body.End() {
+			parallel = append(parallel, sub)
+		}
+	}
+	return parallel
+}
+
+// writtenVars returns the variables declared outside the body of a subtest
+// that the subtest assigns, changes with ++ or --, whose elements or fields it
+// assigns, or that it sends to once it is parallel, with the first identifier
+// writing each. The writes before its call to t.Parallel run before t.Run
+// returns and don't count.
+func writtenVars(pass *analysis.Pass, body *ast.BlockStmt, parallel *testCall) map[types.Object]*ast.Ident {
+	written := make(map[types.Object]*ast.Ident)
+	late := afterParallel(body, parallel)
+	write := func(expr ast.Expr) {
+		ident := baseIdent(expr)
+		if ident == nil || !late(ident.Pos()) {
+			return
+		}
+		v, ok := pass.TypesInfo.Uses[ident].(*types.Var)
+		if !ok || v.IsField() || (body.Pos() <= v.Pos() && v.Pos() < body.End()) {
+			return
+		}
+		if _, ok := written[v]; !ok {
+			written[v] = ident
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				for _, lhs := range n.Lhs {
+					write(lhs)
+				}
+			}
+		case *ast.IncDecStmt:
+			write(n.X)
+		case *ast.SendStmt:
+			write(n.Chan)
+		}
+		return true
+	})
+	return written
+}
+
+// baseIdent returns the variable an expression indexes, selects a field of or
+// dereferences, or the identifier itself.
+func baseIdent(expr ast.Expr) *ast.Ident {
+	for {
+		switch e := ast.Unparen(expr).(type) {
+		case *ast.Ident:
+			return e
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			expr = e.X
+		case *ast.StarExpr:
+			expr = e.X
+		default:
+			return nil
+		}
+	}
+}
+
+// isAssigned reports whether the identifier is the left-hand side of a plain
+// assignment in the body, which writes the variable without reading it.
+func isAssigned(body *ast.BlockStmt, ident *ast.Ident) bool {
+	assigned := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if assigned || n == nil || n.Pos() > ident.Pos() || n.End() <= ident.Pos() {
+			return false
+		}
+		if assign, ok := n.(*ast.AssignStmt); ok && (assign.Tok == token.ASSIGN || assign.Tok == token.DEFINE) {
+			for _, lhs := range assign.Lhs {
+				if ast.Unparen(lhs) == ident {
+					assigned = true
+				}
+			}
+		}
+		return true
+	})
+	return assigned
+}
+
+// groupFix returns a suggested fix that wraps the statements of the body that
+// start the parallel subtests before the read in t.Run("group", ...), so that
+// the group returns once the subtests are done. No fix is returned if the
+// test is a helper taking a testing.TB, if the subtests or the read are not in
+// statements of the body itself, or if the statements declare names used
+// after them.
+func groupFix(pass *analysis.Pass, funcType *ast.FuncType, body *ast.BlockStmt, testVar types.Object, parallel []subtest, read *ast.Ident) []analysis.SuggestedFix {
+	if !isTestingT(testVar.Type()) {
+		// Only a *testing.T can run the group.
+		return nil
+	}
+
+	stmtIndex := func(pos token.Pos) int {
+		for i, stmt := range body.List {
+			if stmt.Pos() <= pos && pos < stmt.End() {
+				return i
+			}
+		}
+		return -1
+	}
+	first, last := -1, -1
+	for _, sub := range parallel {
+		if sub.body.End() >= read.Pos() {
+			continue
+		}
+		i := stmtIndex(sub.body.Pos())
+		if i < 0 {
+			return nil
+		}
+		if first < 0 || i < first {
+			first = i
+		}
+		last = max(last, i)
+	}
+	if first < 0 || stmtIndex(read.Pos()) <= last {
+		return nil
+	}
+
+	group := body.List[first : last+1]
+	declared := make(map[types.Object]bool)
+	for _, stmt := range group {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && pass.TypesInfo.Defs[ident] != nil {
+				declared[pass.TypesInfo.Defs[ident]] = true
+			}
+			return true
+		})
+	}
+	usedAfter := false
+	for _, stmt := range body.List[last+1:] {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && declared[pass.TypesInfo.Uses[ident]] {
+				usedAfter = true
+			}
+			return !usedAfter
+		})
+	}
+	if usedAfter {
+		return nil
+	}
+
+	var paramType ast.Expr
+	for _, field := range funcType.Params.List {
+		for _, ident := range field.Names {
+			if pass.TypesInfo.Defs[ident] == testVar {
+				paramType = field.Type
+			}
+		}
+	}
+	if paramType == nil {
+		return nil
+	}
+
+	start, end := group[0].Pos(), group[len(group)-1].End()
+	indent := indentation(pass.Fset, start)
+	return []analysis.SuggestedFix{{
+		Message: fmt.Sprintf("Wrap the parallel subtests in %s.Run(\"group\", ...)", testVar.Name()),
+		TextEdits: []analysis.TextEdit{
+			{Pos: start, End: start, NewText: fmt.Appendf(nil, "%s.Run(\"group\", func(%s %s) {\n%s", testVar.Name(), testVar.Name(), types.ExprString(paramType), indent)},
+			{Pos: end, End: end, NewText: []byte("\n" + indent + "})")},
+		},
+	}}
+}
+
+// reportRunResults reports the uses of the result of t.Run calls whose
+// subtest calls t.Parallel. t.Run returns true as soon as the subtest calls
+// t.Parallel, before the rest of the subtest runs, so the result doesn't tell
+// whether the subtest failed.
+func (a *parallelAnalyzer) reportRunResults(pass *analysis.Pass, result *testAnalysis, name string, testVar types.Object, body *ast.BlockStmt) {
+	parallel := make(map[token.Pos]subtest)
+	for _, sub := range result.subtests {
+		if child := a.visited[sub.body]; child != nil && child.parallelCall != nil {
+			parallel[sub.run] = sub
+		}
+	}
+	if len(parallel) == 0 {
+		return
+	}
+
+	skip := make(map[*ast.BlockStmt]bool)
+	for _, sub := range result.subtests {
+		skip[sub.body] = true
+	}
+	// discarded holds the t.Run calls whose result is not used.
+	discarded := make(map[ast.Expr]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return !skip[n.Body]
+		case *ast.ExprStmt:
+			discarded[ast.Unparen(n.X)] = true
+		case *ast.GoStmt:
+			discarded[n.Call] = true
+		case *ast.DeferStmt:
+			discarded[n.Call] = true
+		case *ast.CallExpr:
+			sub, ok := parallel[n.Pos()]
+			if !ok || discarded[n] || !isTestRunCall(pass, n, testVar) {
+				return true
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos: n.Pos(),
+				End: n.End(),
+				Message: fmt.Sprintf("Function %s uses the result of %s.Run, which is true as soon as the parallel subtest %s calls t.Parallel, before the subtest is done\n",
+					name, testVar.Name(), sub.name),
+			})
+		}
+		return true
+	})
+}
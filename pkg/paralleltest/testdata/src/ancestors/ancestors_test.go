@@ -0,0 +1,76 @@
+package ancestors
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetenvInParallelParent(t *testing.T) {
+	t.Parallel()
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("foo", "bar") // want "Function TestSetenvInParallelParent/env calls t.Setenv under the parallel test TestSetenvInParallelParent, which panics: t.Setenv and t.Chdir can't be used in subtests of parallel tests"
+	})
+}
+
+func TestChdirInParallelGrandparent(t *testing.T) {
+	t.Parallel()
+	t.Run("group", func(t *testing.T) {
+		t.Run("dir", func(t *testing.T) {
+			t.Chdir(os.TempDir()) // want "Function TestChdirInParallelGrandparent/group/dir calls t.Chdir under the parallel test TestChdirInParallelGrandparent, which panics"
+		})
+	})
+}
+
+func TestSetenvUnderParallelSubtest(t *testing.T) {
+	t.Setenv("foo", "bar")
+	t.Run("parallel", func(t *testing.T) {
+		t.Parallel()
+		t.Run("env", func(t *testing.T) {
+			t.Setenv("foo", "baz") // want "Function TestSetenvUnderParallelSubtest/parallel/env calls t.Setenv under the parallel test TestSetenvUnderParallelSubtest/parallel, which panics"
+		})
+	})
+}
+
+func TestParallelAfterRun(t *testing.T) {
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("foo", "bar")
+	})
+	t.Parallel()
+}
+
+func setup(t *testing.T) {
+	t.Setenv("foo", "bar")
+}
+
+func envSubtest(t *testing.T) {
+	setup(t) // want "Function TestNamedSubtestWithHelper/named calls t.Setenv through setup under the parallel test TestNamedSubtestWithHelper, which panics"
+}
+
+func TestNamedSubtestWithHelper(t *testing.T) {
+	t.Parallel()
+	t.Run("named", envSubtest)
+}
+
+func build(name string) func(t *testing.T) {
+	return func(t *testing.T) {
+		t.Setenv("name", name) // want "Function TestBuilderSubtest/<name> calls t.Setenv under the parallel test TestBuilderSubtest, which panics"
+	}
+}
+
+func TestBuilderSubtest(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"a", "b"} {
+		t.Run(name, build(name))
+	}
+}
+
+func runEnv(t *testing.T) {
+	t.Run("helper", func(t *testing.T) {
+		setup(t) // want "Function TestSubtestOfHelper/helper calls t.Setenv through setup under the parallel test TestSubtestOfHelper, which panics"
+	})
+}
+
+func TestSubtestOfHelper(t *testing.T) {
+	t.Parallel()
+	runEnv(t)
+}
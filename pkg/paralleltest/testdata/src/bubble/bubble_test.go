@@ -0,0 +1,52 @@
+package bubble
+
+import (
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func TestBubble(t *testing.T) {
+	t.Parallel()
+	synctest.Test(t, func(t *testing.T) {
+		time.Sleep(time.Second)
+		synctest.Wait()
+	})
+}
+
+func TestParallelInBubble(t *testing.T) { // want "Function TestParallelInBubble missing the call to method parallel"
+	synctest.Test(t, func(t *testing.T) {
+		t.Parallel() // want "Function literal calls t.Parallel in a synctest bubble, which panics: Parallel, Run and Deadline can't be called on the T of synctest.Test"
+	})
+}
+
+func TestRunInBubble(t *testing.T) {
+	t.Parallel()
+	synctest.Test(t, func(t *testing.T) {
+		t.Run("sub", func(t *testing.T) { // want "Function literal calls t.Run in a synctest bubble"
+		})
+	})
+}
+
+func TestDeadlineInBubble(t *testing.T) {
+	t.Parallel()
+	synctest.Test(t, bubble)
+}
+
+func bubble(t *testing.T) {
+	if _, ok := t.Deadline(); ok { // want "Function bubble calls t.Deadline in a synctest bubble"
+		t.Log("deadline")
+	}
+	parallel(t) // want "Function bubble calls t.Parallel through parallel in a synctest bubble"
+}
+
+func parallel(t *testing.T) {
+	t.Helper()
+	t.Parallel()
+}
+
+func TestSetenvInBubble(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		t.Setenv("KEY", "value")
+	})
+}
@@ -0,0 +1,11 @@
+package callgraph
+
+import "testing"
+
+func Setup(t *testing.T) { // want Setup:"cantParallel: calls configure → \\(\\*testing.T\\).Setenv"
+	configure(t)
+}
+
+func configure(t *testing.T) {
+	t.Setenv("LEVEL", "debug")
+}
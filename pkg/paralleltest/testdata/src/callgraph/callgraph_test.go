@@ -0,0 +1,84 @@
+package callgraph
+
+import (
+	"os"
+	"testing"
+)
+
+type fixture interface {
+	Setup(t *testing.T)
+}
+
+type envFixture struct{}
+
+func (envFixture) Setup(t *testing.T) {
+	t.Setenv("HOME", os.TempDir())
+}
+
+func TestInterface(t *testing.T) {
+	var f fixture = envFixture{}
+	f.Setup(t)
+}
+
+type parallelizer interface {
+	Parallel()
+}
+
+func TestParallelInterface(t *testing.T) {
+	var p parallelizer = t
+	p.Parallel()
+}
+
+func do(f func()) {
+	f()
+}
+
+func TestClosure(t *testing.T) {
+	do(func() {
+		Setup(t)
+	})
+}
+
+func TestMissing(t *testing.T) { // want "Function TestMissing missing the call to method parallel"
+	t.Log("missing")
+}
+
+func noop(t *testing.T) {
+	t.Log("noop")
+}
+
+func TestDynamic(t *testing.T) { // want "Function TestDynamic missing the call to method parallel"
+	run := []func(*testing.T){noop}
+	run[0](t)
+}
+
+func TestOther(t *testing.T) {
+	t.Parallel()
+}
+
+func TestParallelChain(t *testing.T) {
+	t.Parallel() // want "Function TestParallelChain calls t.Parallel but calls \\(envFixture\\).Setup → \\(\\*testing.T\\).Setenv, which can't be used with t.Parallel"
+	var f fixture = envFixture{}
+	f.Setup(t)
+}
+
+func TestParallelMethodValue(t *testing.T) {
+	parallel := t.Parallel
+	parallel()
+}
+
+var shared *testing.T
+
+func parallelShared() {
+	shared.Parallel()
+}
+
+func TestSharedT(t *testing.T) { // want "Function TestSharedT missing the call to method parallel"
+	parallelShared()
+}
+
+func TestOtherT(t *testing.T) { // want "Function TestOtherT missing the call to method parallel"
+	do(func() {
+		shared.Parallel()
+	})
+}
@@ -0,0 +1,23 @@
+package callgraphvta
+
+import (
+	"os"
+	"testing"
+)
+
+type suite struct {
+	setup func(t *testing.T)
+}
+
+func chdir(t *testing.T) {
+	t.Chdir(os.TempDir())
+}
+
+func TestFuncField(t *testing.T) {
+	s := suite{setup: chdir}
+	s.setup(t)
+}
+
+func TestOther(t *testing.T) {
+	t.Parallel()
+}
@@ -1,9 +1,11 @@
 package cleanup
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 )
 
 // Test with t.Parallel and defer - should report an issue when checkcleanup flag is enabled
@@ -141,3 +143,105 @@ func TestDeferOkayInHelper(t *testing.T) {
 		t.Parallel()
 	})
 }
+
+func TestDeferCapturesArguments(t *testing.T) {
+	t.Parallel()
+
+	name := "first.tmp"
+	defer os.Remove(name) // want "Function TestDeferCapturesArguments uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n"
+	name = "second.tmp"
+
+	t.Run("subtest", func(t *testing.T) {
+		t.Parallel()
+		fmt.Println(name)
+	})
+}
+
+func TestDeferFunctionLiteralWithArguments(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 2; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+		})
+	}
+	count := 2
+	defer func(n int) { // want "Function TestDeferFunctionLiteralWithArguments uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n"
+		fmt.Println(n)
+	}(count)
+	count++
+}
+
+func TestDeferNamedSubtest(t *testing.T) {
+	t.Parallel()
+	t.Run("1", namedSubtestWithDefer)
+}
+
+func namedSubtestWithDefer(t *testing.T) {
+	t.Parallel()
+	defer fmt.Println("cleanup") // want "Function namedSubtestWithDefer uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n"
+	t.Run("2", func(t *testing.T) {
+		t.Parallel()
+	})
+}
+
+func TestDeferBuilderSubtest(t *testing.T) {
+	t.Parallel()
+	t.Run("1", builderWithDefer())
+}
+
+func builderWithDefer() func(t *testing.T) {
+	return func(t *testing.T) {
+		t.Parallel()
+		defer fmt.Println("cleanup") // want "Function builderWithDefer uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n"
+		t.Run("2", func(t *testing.T) {
+			t.Parallel()
+		})
+	}
+}
+
+func TestDeferLiteralReturns(t *testing.T) {
+	t.Parallel()
+
+	var f *os.File
+	defer func() { // want "Function TestDeferLiteralReturns uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n"
+		if f == nil {
+			return
+		}
+		f.Close()
+	}()
+	defer fmt.Println("cleanup") // want "Function TestDeferLiteralReturns uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n"
+
+	t.Run("subtest", func(t *testing.T) {
+		t.Parallel()
+		f, _ = os.Create("test.tmp")
+	})
+}
+
+func TestDeferReassignedCancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel() // want "Function TestDeferReassignedCancel uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n"
+	ctx, cancel = context.WithTimeout(ctx, time.Millisecond)
+	defer cancel() // want "Function TestDeferReassignedCancel uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n"
+
+	t.Run("subtest", func(t *testing.T) {
+		t.Parallel()
+		<-ctx.Done()
+	})
+}
+
+func TestDeferRecover(t *testing.T) {
+	t.Parallel()
+
+	defer func() { // want "Function TestDeferRecover uses defer with t.Parallel, use t.Cleanup instead to ensure cleanup runs after parallel subtests complete\n"
+		if r := recover(); r != nil {
+			t.Error(r)
+		}
+	}()
+
+	t.Run("subtest", func(t *testing.T) {
+		t.Parallel()
+	})
+}
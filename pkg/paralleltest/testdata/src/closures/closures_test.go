@@ -0,0 +1,73 @@
+package closures
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+var mu sync.Mutex
+
+func withLock(f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	f()
+}
+
+func TestSetenvInLockedClosure(t *testing.T) {
+	withLock(func() {
+		t.Setenv("foo", "bar")
+	})
+}
+
+func TestChdirInImmediatelyInvokedClosure(t *testing.T) {
+	func() {
+		t.Chdir("foo")
+	}()
+}
+
+func TestSetenvInCleanup(t *testing.T) {
+	t.Cleanup(func() {
+		t.Setenv("foo", "bar")
+	})
+}
+
+func TestParallelInImmediatelyInvokedClosure(t *testing.T) {
+	func() {
+		t.Parallel()
+	}()
+}
+
+func TestParallelInDeferredClosure(t *testing.T) {
+	defer func() {
+		t.Parallel() // want "Function TestParallelInDeferredClosure defers t.Parallel, which runs once the test is over and doesn't make it parallel"
+	}()
+}
+
+func TestNestedClosures(t *testing.T) {
+	withLock(func() {
+		func() {
+			t.Setenv("foo", "bar")
+		}()
+	})
+}
+
+func TestClosureWithoutTestCalls(t *testing.T) { // want "Function TestClosureWithoutTestCalls missing the call to method parallel"
+	withLock(func() {
+		fmt.Println("locked")
+	})
+}
+
+func TestRunInClosure(t *testing.T) {
+	t.Parallel()
+	withLock(func() {
+		t.Run("1", func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+			fmt.Println("1")
+		})
+	})
+	func() {
+		t.Run("2", func(t *testing.T) {
+			t.Parallel()
+		})
+	}()
+}
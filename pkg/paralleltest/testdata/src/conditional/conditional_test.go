@@ -0,0 +1,36 @@
+package conditional
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConditionalParallel(t *testing.T) {
+	if os.Getenv("PARALLEL") != "" {
+		t.Parallel() // want "Function TestConditionalParallel calls t.Parallel only under a condition, the test may not run in parallel"
+	}
+}
+
+func TestParallelInSwitch(t *testing.T) {
+	switch os.Getenv("MODE") {
+	case "parallel":
+		t.Parallel() // want "Function TestParallelInSwitch calls t.Parallel only under a condition"
+	default:
+	}
+}
+
+func TestParallelInSubtest(t *testing.T) {
+	t.Parallel()
+	if os.Getenv("SUBTEST") != "" {
+		t.Run("sub", func(t *testing.T) {
+			t.Parallel()
+		})
+	}
+}
+
+func TestSkipThenParallel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("short")
+	}
+	t.Parallel()
+}
@@ -0,0 +1,122 @@
+package deadlock
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWaitForSubtests(t *testing.T) {
+	t.Parallel()
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		wg.Add(1)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			defer wg.Done()
+		})
+	}
+	wg.Wait() // want "Function TestWaitForSubtests calls wg.Wait, which waits for its parallel subtests to call wg.Done, but they only run once TestWaitForSubtests returns: the test deadlocks"
+}
+
+func TestReceiveFromSubtest(t *testing.T) {
+	t.Parallel()
+	done := make(chan struct{})
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+		close(done)
+	})
+	<-done // want "Function TestReceiveFromSubtest receives from done, which waits for its parallel subtests to close done"
+}
+
+func TestRangeOverSubtestResults(t *testing.T) {
+	t.Parallel()
+	results := make(chan int)
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+		results <- 1
+	})
+	for result := range results { // want "Function TestRangeOverSubtestResults ranges over results, which waits for its parallel subtests to send to results"
+		t.Log(result)
+	}
+}
+
+func TestLockReleasedBySubtest(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	mu.Lock()
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+		mu.Unlock()
+	})
+	mu.Lock() // want "Function TestLockReleasedBySubtest calls mu.Lock, which waits for its parallel subtests to call mu.Unlock"
+}
+
+func TestWaitInCleanup(t *testing.T) {
+	t.Parallel()
+	var wg sync.WaitGroup
+	t.Cleanup(wg.Wait)
+	t.Cleanup(func() {
+		wg.Wait()
+	})
+	wg.Add(1)
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+		defer wg.Done()
+	})
+}
+
+func TestWaitInGroup(t *testing.T) {
+	t.Parallel()
+	var wg sync.WaitGroup
+	t.Run("group", func(t *testing.T) {
+		wg.Add(1)
+		t.Run("sub", func(t *testing.T) {
+			t.Parallel()
+			defer wg.Done()
+		})
+	})
+	wg.Wait()
+}
+
+func TestSerialSubtest(t *testing.T) {
+	t.Parallel()
+	done := make(chan struct{}, 1)
+	t.Run("sub", func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run"
+		done <- struct{}{}
+	})
+	<-done
+}
+
+func TestMutexInSubtests(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+		mu.Lock()
+		defer mu.Unlock()
+	})
+	mu.Lock()
+	defer mu.Unlock()
+}
+
+func TestDoneBeforeParallel(t *testing.T) {
+	t.Parallel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	t.Run("a", func(t *testing.T) {
+		wg.Done()
+		t.Parallel()
+	})
+	wg.Wait()
+}
+
+func TestDeferredDoneBeforeParallel(t *testing.T) {
+	t.Parallel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	t.Run("a", func(t *testing.T) {
+		defer wg.Done()
+		t.Parallel()
+	})
+	wg.Wait() // want "Function TestDeferredDoneBeforeParallel calls wg.Wait, which waits for its parallel subtests to call wg.Done"
+}
@@ -0,0 +1,6 @@
+package discovery
+
+import "testing"
+
+// Not a _test.go file, so go test never runs it.
+func TestInNonTestFile(t *testing.T) {}
@@ -0,0 +1,11 @@
+package discovery_test
+
+import "testing"
+
+func Testing(t *testing.T) {}
+
+func TestExternal(t *testing.T) {} // want "Function TestExternal missing the call to method parallel"
+
+func TestExternalWithParallel(t *testing.T) {
+	t.Parallel()
+}
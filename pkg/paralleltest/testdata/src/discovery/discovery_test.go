@@ -0,0 +1,25 @@
+package discovery
+
+import "testing"
+
+// A lowercase character after "Test" means this isn't a test.
+func Testify(t *testing.T) {}
+
+func Test(t *testing.T) {} // want "Function Test missing the call to method parallel"
+
+func Test_underscore(t *testing.T) {} // want "Function Test_underscore missing the call to method parallel"
+
+func Test1(t *testing.T) {} // want "Function Test1 missing the call to method parallel"
+
+func TestÜber(t *testing.T) {} // want "Function TestÜber missing the call to method parallel"
+
+func Testünicode(t *testing.T) {}
+
+type suite struct{}
+
+// Methods aren't tests, even with a *testing.T parameter.
+func (suite) TestMethod(t *testing.T) {}
+
+func TestMain(m *testing.M) {
+	m.Run()
+}
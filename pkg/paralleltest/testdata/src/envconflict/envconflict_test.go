@@ -0,0 +1,99 @@
+package envconflict
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetenvAfterParallel(t *testing.T) {
+	t.Parallel()
+	t.Setenv("foo", "bar") // want "Function TestSetenvAfterParallel calls t.Setenv after t.Parallel, which panics: t.Setenv and t.Chdir can't be used with t.Parallel"
+}
+
+func TestParallelAfterChdir(t *testing.T) {
+	t.Chdir(os.TempDir())
+	t.Parallel() // want "Function TestParallelAfterChdir calls t.Parallel after t.Chdir, which panics"
+}
+
+func setup(tb testing.TB) {
+	tb.Helper()
+	tb.Setenv("foo", "bar")
+}
+
+func parallel(t *testing.T) {
+	t.Helper()
+	t.Parallel()
+}
+
+func TestParallelAfterSetenvHelper(t *testing.T) {
+	setup(t)
+	t.Parallel() // want "Function TestParallelAfterSetenvHelper calls t.Parallel after t.Setenv through setup, which panics"
+}
+
+func TestSetenvHelperAfterParallelHelper(t *testing.T) {
+	parallel(t)
+	setup(t) // want "Function TestSetenvHelperAfterParallelHelper calls t.Setenv through setup after t.Parallel through parallel, which panics"
+}
+
+func TestSubtest(t *testing.T) {
+	t.Parallel()
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("foo", "bar") // want "Function TestSubtest/env calls t.Setenv under the parallel test TestSubtest"
+		t.Parallel()           // want "Function literal calls t.Parallel after t.Setenv, which panics"
+	})
+}
+
+func TestSetenvInSubtestOnly(t *testing.T) {
+	t.Setenv("foo", "bar")
+	t.Run("parallel", func(t *testing.T) {
+		t.Parallel()
+	})
+}
+
+func TestSetenvWithoutParallel(t *testing.T) {
+	t.Setenv("foo", "bar")
+}
+
+func TestSetenvOrParallel(t *testing.T) {
+	if env := os.Getenv("FOO"); env != "" {
+		t.Setenv("foo", env)
+	} else {
+		t.Parallel()
+	}
+}
+
+func TestSetenvOrParallelSwitch(t *testing.T) {
+	switch env := os.Getenv("FOO"); env {
+	case "":
+		t.Parallel()
+	default:
+		t.Setenv("foo", env)
+	}
+}
+
+func TestSetenvOrParallelReturn(t *testing.T) {
+	if env := os.Getenv("FOO"); env != "" {
+		t.Setenv("foo", env)
+		return
+	}
+	t.Parallel()
+}
+
+func TestSetenvOrParallelPerCase(t *testing.T) {
+	for _, tc := range []struct{ name, env string }{{"a", ""}, {"b", "bar"}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != "" {
+				t.Setenv("foo", tc.env)
+			} else {
+				t.Parallel()
+			}
+		})
+	}
+}
+
+func TestConditionalSetenv(t *testing.T) {
+	if env := os.Getenv("FOO"); env != "" {
+		t.Setenv("foo", env)
+	}
+	t.Parallel() // want "Function TestConditionalSetenv calls t.Parallel after t.Setenv, which may panic: t.Setenv and t.Chdir can't be used with t.Parallel"
+}
@@ -0,0 +1,48 @@
+package facts
+
+import (
+	"testing"
+
+	"facts/testutil"
+)
+
+func TestWithEnvHelper(t *testing.T) {
+	testutil.WithEnv(t, "foo", "bar")
+}
+
+func TestInTempDirHelper(t *testing.T) {
+	testutil.InTempDir(t)
+}
+
+func TestParallelHelper(t *testing.T) {
+	testutil.Parallel(t)
+}
+
+func TestNoEffectHelper(t *testing.T) { // want "Function TestNoEffectHelper missing the call to method parallel"
+	testutil.Case(t)
+}
+
+func TestMethodHelper(t *testing.T) {
+	var f testutil.Fixture
+	f.Setup(t)
+}
+
+func TestImportedSubtests(t *testing.T) {
+	t.Parallel()
+	t.Run("1", testutil.Case) // want "Function Case missing the call to method parallel in the t.Run\n"
+	t.Run("2", testutil.ParallelCase)
+}
+
+func TestParallelHelperThenSetenv(t *testing.T) {
+	testutil.Parallel(t)
+	t.Setenv("foo", "bar") // want "Function TestParallelHelperThenSetenv calls t.Setenv after t.Parallel through Parallel, which panics: t.Setenv and t.Chdir can't be used with t.Parallel"
+}
+
+func TestParallelThenEnvHelper(t *testing.T) {
+	t.Parallel()
+	testutil.WithEnv(t, "foo", "bar") // want "Function TestParallelThenEnvHelper calls t.Setenv through WithEnv after t.Parallel, which panics: t.Setenv and t.Chdir can't be used with t.Parallel"
+}
+
+func TestDeferredParallelHelper(t *testing.T) {
+	testutil.DeferParallel(t) // want "Function TestDeferredParallelHelper defers t.Parallel through DeferParallel, which runs once the test is over and doesn't make it parallel"
+}
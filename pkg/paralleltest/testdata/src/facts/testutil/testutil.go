@@ -0,0 +1,49 @@
+package testutil
+
+import (
+	"os"
+	"testing"
+)
+
+func WithEnv(tb testing.TB, key, value string) { // want WithEnv:"cantParallel: calls \\(testing.TB\\).Setenv"
+	tb.Helper()
+	tb.Setenv(key, value)
+}
+
+func InTempDir(t *testing.T) { // want InTempDir:"cantParallel: calls \\(\\*testing.T\\).Chdir"
+	t.Chdir(os.TempDir())
+}
+
+func Parallel(t *testing.T) { // want Parallel:"parallel"
+	t.Parallel()
+}
+
+func RunCases(t *testing.T, cases map[string]func(t *testing.T)) { // want RunCases:"parallel; subtests: 1"
+	t.Parallel()
+	for name, tc := range cases {
+		t.Run(name, tc)
+	}
+}
+
+func Case(t *testing.T) { // want Case:"no effect on t.Parallel"
+	t.Log("case")
+}
+
+func ParallelCase(t *testing.T) { // want ParallelCase:"parallel"
+	t.Parallel()
+}
+
+type Fixture struct{}
+
+func (Fixture) Setup(t *testing.T) { // want Setup:"cantParallel: calls \\(\\*testing.T\\).Setenv"
+	t.Setenv("foo", "bar")
+}
+
+// unexported helpers can't be called from other packages.
+func setup(t *testing.T) {
+	t.Parallel()
+}
+
+func DeferParallel(t *testing.T) { // want DeferParallel:"parallel"
+	defer t.Parallel()
+}
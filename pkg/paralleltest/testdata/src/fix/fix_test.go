@@ -0,0 +1,77 @@
+package fix
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMissing(t *testing.T) { // want "Function TestMissing missing the call to method parallel"
+	fmt.Println("missing")
+}
+
+func TestEmpty(t *testing.T) {} // want "Function TestEmpty missing the call to method parallel"
+
+func TestOtherName(q *testing.T) { // want "Function TestOtherName missing the call to method parallel"
+	fmt.Println("other name")
+}
+
+func TestAfterHelper(t *testing.T) { // want "Function TestAfterHelper missing the call to method parallel"
+	t.Helper()
+	fmt.Println("after helper")
+}
+
+func TestAfterSkip(t *testing.T) { // want "Function TestAfterSkip missing the call to method parallel"
+	t.Helper()
+	t.Skip("flaky")
+	fmt.Println("after skip")
+}
+
+func TestOnlyGuards(t *testing.T) { // want "Function TestOnlyGuards missing the call to method parallel"
+	t.Skipf("skipped %d", 1)
+}
+
+func TestConditionalSkip(t *testing.T) { // want "Function TestConditionalSkip missing the call to method parallel"
+	if testing.Short() {
+		t.Skip("short")
+	}
+	fmt.Println("conditional skip")
+}
+
+func TestInlineSubtest(t *testing.T) {
+	t.Parallel()
+	t.Run("1", func(x *testing.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+		x.Helper()
+		fmt.Println("1")
+	})
+	t.Run("2", func(t *testing.T) {}) // want "Function literal missing the call to method parallel in the t.Run\n"
+}
+
+func TestNamedSubtest(t *testing.T) {
+	t.Parallel()
+	t.Run("1", namedSubtest) // want "Function namedSubtest missing the call to method parallel in the t.Run\n"
+	t.Run("2", namedSubtest) // want "Function namedSubtest missing the call to method parallel in the t.Run\n"
+}
+
+func namedSubtest(st *testing.T) {
+	fmt.Println("named")
+}
+
+func TestBuilderSubtest(t *testing.T) {
+	t.Parallel()
+	t.Run("1", builder()) // want "Function builder missing the call to method parallel in the t.Run\n"
+}
+
+func builder() func(t *testing.T) {
+	return func(t *testing.T) {
+		fmt.Println("builder")
+	}
+}
+
+func TestUnnamedParam(*testing.T) { // want "Function TestUnnamedParam has an unnamed \\*testing.T parameter and is missing the call to method parallel"
+	fmt.Println("unnamed")
+}
+
+func TestBlankParam(_ *testing.T) { // want "Function TestBlankParam has an unnamed \\*testing.T parameter and is missing the call to method parallel"
+	t := "shadowed"
+	fmt.Println(t)
+}
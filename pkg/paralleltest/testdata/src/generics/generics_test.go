@@ -0,0 +1,91 @@
+package generics
+
+import (
+	"fmt"
+	"testing"
+)
+
+type testCase[V any] struct {
+	name string
+	in   V
+}
+
+func runCases[V any](t *testing.T, cases []testCase[V]) {
+	t.Parallel()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+			fmt.Println(tc.in)
+		})
+	}
+}
+
+func TestExplicitInstantiation(t *testing.T) {
+	runCases[int](t, []testCase[int]{{name: "one", in: 1}})
+}
+
+func TestInferredInstantiation(t *testing.T) {
+	runCases(t, []testCase[string]{{name: "one", in: "1"}})
+}
+
+func setenvCases[K comparable, V any](t *testing.T, cases map[K]V) {
+	t.Setenv("foo", fmt.Sprint(len(cases)))
+}
+
+func TestMultipleTypeArguments(t *testing.T) {
+	setenvCases[string, int](t, map[string]int{"one": 1})
+}
+
+func setenvTB[TB testing.TB](tb TB) {
+	tb.Setenv("foo", "bar")
+}
+
+func TestTypeParameterHelper(t *testing.T) {
+	setenvTB(t)
+}
+
+func build[V any](tc testCase[V]) func(*testing.T) {
+	return func(t *testing.T) {
+		fmt.Println(tc.in)
+	}
+}
+
+func buildParallel[V any](tc testCase[V]) func(*testing.T) {
+	return func(t *testing.T) {
+		t.Parallel()
+		fmt.Println(tc.in)
+	}
+}
+
+func TestGenericBuilders(t *testing.T) {
+	t.Parallel()
+	tc := testCase[int]{name: "one", in: 1}
+	t.Run("explicit", build[int](tc)) // want "Function build missing the call to method parallel in the t.Run\n"
+	t.Run("inferred", build(tc))      // want "Function build missing the call to method parallel in the t.Run\n"
+	t.Run("parallel", buildParallel[int](tc))
+}
+
+func subtest[V any](t *testing.T) {
+	var v V
+	fmt.Println(v)
+}
+
+func parallelSubtest[V any](t *testing.T) {
+	t.Parallel()
+}
+
+func TestGenericSubtests(t *testing.T) {
+	t.Parallel()
+	t.Run("1", subtest[int]) // want "Function subtest missing the call to method parallel in the t.Run\n"
+	t.Run("2", parallelSubtest[int])
+}
+
+type fixture[V any] struct{ value V }
+
+func (f fixture[V]) setup(t *testing.T) {
+	t.Parallel()
+}
+
+func TestGenericMethod(t *testing.T) {
+	f := fixture[int]{value: 1}
+	f.setup(t)
+}
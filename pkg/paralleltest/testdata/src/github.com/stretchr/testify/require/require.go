@@ -0,0 +1,16 @@
+// Package require stands in for github.com/stretchr/testify/require.
+package require
+
+// TestingT is the interface of the tests that assertions report to.
+type TestingT interface {
+	Errorf(format string, args ...any)
+	FailNow()
+}
+
+// NoError fails the test now if err is not nil.
+func NoError(t TestingT, err error, msgAndArgs ...any) {
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,27 @@
+// Package errgroup stands in for golang.org/x/sync/errgroup.
+package errgroup
+
+import "sync"
+
+// A Group is a collection of goroutines working on subtasks of the same task.
+type Group struct {
+	wg  sync.WaitGroup
+	err error
+}
+
+// Go calls the given function in a new goroutine.
+func (g *Group) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil && g.err == nil {
+			g.err = err
+		}
+	}()
+}
+
+// Wait blocks until all function calls from the Go method have returned.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
@@ -0,0 +1,113 @@
+package goroutines
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func work() error {
+	return errors.New("failed")
+}
+
+func TestFatalInGoroutine(t *testing.T) {
+	t.Parallel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := work(); err != nil {
+			t.Fatal(err) // want "Goroutine calls t.Fatal: FailNow and the methods calling it must be called from the goroutine running the test"
+		}
+	}()
+	<-done
+}
+
+func TestRequireInErrgroup(t *testing.T) {
+	t.Parallel()
+	var g errgroup.Group
+	g.Go(func() error {
+		require.NoError(t, work()) // want "Goroutine calls require.NoError, which calls FailNow on failure: FailNow must be called from the goroutine running the test"
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFailNowInWaitGroupGo(t *testing.T) {
+	t.Parallel()
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		t.FailNow() // want "Goroutine calls t.FailNow"
+	})
+	wg.Wait()
+}
+
+func TestLogInGoroutineNotWaited(t *testing.T) {
+	t.Parallel()
+	go func() {
+		t.Log("done") // want "Goroutine calls t.Log and the test doesn't wait for it, which panics once the test has completed"
+	}()
+}
+
+func TestErrorInWaitedGoroutine(t *testing.T) {
+	t.Parallel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := work(); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+}
+
+func TestErrorsInErrgroup(t *testing.T) {
+	t.Parallel()
+	var g errgroup.Group
+	g.Go(func() error {
+		t.Log("working")
+		return work()
+	})
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubtestGoroutine(t *testing.T) {
+	t.Parallel()
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+		go func() {
+			t.Skip("later") // want "Goroutine calls t.Skip"
+		}()
+	})
+}
+
+type waiter struct{}
+
+func (waiter) Wait() {}
+
+func TestLogInGoroutineOtherWait(t *testing.T) {
+	t.Parallel()
+	var w waiter
+	go func() {
+		t.Log("done") // want "Goroutine calls t.Log and the test doesn't wait for it, which panics once the test has completed"
+	}()
+	w.Wait()
+}
+
+func logLater(t *testing.T) {
+	go func() {
+		t.Log("done")
+	}()
+}
+
+func TestGoroutineInHelper(t *testing.T) {
+	t.Parallel()
+	logLater(t)
+}
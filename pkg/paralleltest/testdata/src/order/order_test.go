@@ -0,0 +1,79 @@
+package order
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRunBeforeParallel(t *testing.T) {
+	t.Run("first", func(t *testing.T) {
+		t.Parallel()
+	})
+	t.Parallel() // want "Function TestRunBeforeParallel calls t.Parallel after t.Run, t.Parallel must come first so that the test doesn't hold up the serial tests"
+	t.Run("second", func(t *testing.T) {
+		t.Parallel()
+	})
+}
+
+func TestReadFileBeforeParallel(t *testing.T) {
+	data, err := os.ReadFile("testdata/input.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Parallel() // want "Function TestReadFileBeforeParallel calls t.Parallel after os.ReadFile"
+	_ = data
+}
+
+func TestSubtestBeforeParallel(t *testing.T) {
+	t.Parallel()
+	t.Run("sub", func(t *testing.T) {
+		time.Sleep(time.Millisecond)
+		t.Parallel() // want "Function literal calls t.Parallel after time.Sleep"
+	})
+}
+
+func setup(t *testing.T) {
+	t.Helper()
+	t.Run("setup", func(t *testing.T) {
+		t.Parallel()
+	})
+}
+
+func TestHelperBeforeParallel(t *testing.T) {
+	setup(t)
+	t.Parallel() // want "Function TestHelperBeforeParallel calls t.Parallel after t.Run through setup"
+}
+
+func parallel(t *testing.T) {
+	t.Helper()
+	t.Parallel()
+}
+
+func TestParallelHelperAfterSleep(t *testing.T) {
+	time.Sleep(time.Millisecond)
+	parallel(t) // want "Function TestParallelHelperAfterSleep calls t.Parallel through parallel after time.Sleep"
+}
+
+func TestParallelFirst(t *testing.T) {
+	t.Parallel()
+	time.Sleep(time.Millisecond)
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+	})
+}
+
+func TestCleanupBeforeParallel(t *testing.T) {
+	t.Cleanup(func() {
+		_ = os.Remove("testdata/output.txt")
+	})
+	t.Parallel()
+}
+
+func TestGuardsBeforeParallel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("short")
+	}
+	t.Parallel()
+	_, _ = os.ReadFile("testdata/input.txt")
+}
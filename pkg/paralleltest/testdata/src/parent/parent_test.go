@@ -0,0 +1,101 @@
+package parent
+
+import (
+	"errors"
+	"testing"
+
+	"parent/require"
+)
+
+func TestParentMethodInSubtest(t *testing.T) {
+	t.Parallel()
+	t.Run("sub", func(st *testing.T) {
+		st.Parallel()
+		t.Log("done") // want "Function literal of the subtest sub uses t, the T of an enclosing test, which reports to the wrong test and may panic"
+	})
+}
+
+func TestParentPassedToRequire(t *testing.T) {
+	t.Parallel()
+	var err error
+	t.Run("sub", func(st *testing.T) {
+		st.Parallel()
+		require.NoError(t, err) // want "Function literal of the subtest sub uses t, the T of an enclosing test"
+	})
+}
+
+func TestGrandparentInNestedSubtest(t *testing.T) {
+	t.Parallel()
+	t.Run("outer", func(outer *testing.T) {
+		outer.Parallel()
+		outer.Run("inner", func(inner *testing.T) {
+			inner.Parallel()
+			if errors.Is(nil, nil) {
+				t.Fail()     // want "Function literal of the subtest inner uses t, the T of an enclosing test"
+				outer.Fail() // want "Function literal of the subtest inner uses outer, the T of an enclosing test"
+			}
+		})
+	})
+}
+
+func TestUnnamedSubtestParam(t *testing.T) {
+	t.Parallel()
+	t.Run("sub", func(*testing.T) { // want "Function literal missing the call to method parallel in the t.Run"
+		t.Log("done") // want "Function literal of the subtest sub uses t, the T of an enclosing test"
+	})
+}
+
+func TestShadowedSubtestParam(t *testing.T) {
+	t.Parallel()
+	t.Run("sub", func(st *testing.T) {
+		st.Parallel()
+		for _, st := range []string{"a"} {
+			t.Log(st) // want "Function literal of the subtest sub uses t, the T of an enclosing test"
+		}
+	})
+}
+
+func TestOwnT(t *testing.T) {
+	t.Parallel()
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+		t.Log("done")
+	})
+}
+
+func TestParentInFuncVariable(t *testing.T) {
+	t.Parallel()
+	run := func(st *testing.T) {
+		st.Parallel()
+		t.Log("done") // want "Function literal of the subtest a uses t, the T of an enclosing test"
+	}
+	t.Run("a", run)
+}
+
+func TestParentInTableField(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		test func(*testing.T)
+	}{
+		{"a", func(st *testing.T) {
+			st.Parallel()
+			t.Log("done") // want "Function literal of the subtest <tc.name> uses t, the T of an enclosing test"
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, tc.test)
+	}
+}
+
+func parentBuilder(t *testing.T) func(*testing.T) {
+	return func(st *testing.T) {
+		st.Parallel()
+		t.Log("done") // want "Function literal of the subtest a uses t, the T of an enclosing test"
+	}
+}
+
+func TestParentInBuilder(t *testing.T) {
+	t.Parallel()
+	t.Run("a", parentBuilder(t))
+}
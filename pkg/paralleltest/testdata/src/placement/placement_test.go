@@ -0,0 +1,116 @@
+package placement
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestParallelInGoroutine(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t.Parallel() // want "Function TestParallelInGoroutine calls t.Parallel in a goroutine, t.Parallel must be called by the test itself"
+	}()
+	wg.Wait()
+}
+
+func TestDeferredParallel(t *testing.T) {
+	defer t.Parallel() // want "Function TestDeferredParallel defers t.Parallel, which runs once the test is over and doesn't make it parallel"
+}
+
+func TestParallelInCleanup(t *testing.T) {
+	t.Cleanup(func() {
+		t.Parallel() // want "Function TestParallelInCleanup calls t.Parallel in t.Cleanup, which runs once the test is over and doesn't make it parallel"
+	})
+}
+
+func TestParallelInLoop(t *testing.T) {
+	for range 2 {
+		t.Parallel() // want "Function TestParallelInLoop calls t.Parallel in a loop, which panics: t.Parallel can't be called more than once"
+	}
+}
+
+func TestParallelTwice(t *testing.T) {
+	t.Parallel()
+	t.Parallel() // want "Function TestParallelTwice calls t.Parallel after t.Parallel, which panics: t.Parallel can't be called more than once"
+}
+
+func parallel(t *testing.T) {
+	t.Helper()
+	t.Parallel()
+}
+
+func TestParallelTwiceThroughHelper(t *testing.T) {
+	t.Parallel()
+	parallel(t) // want "Function TestParallelTwiceThroughHelper calls t.Parallel through parallel after t.Parallel, which panics"
+}
+
+func TestHelperInGoroutine(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parallel(t) // want "Function TestHelperInGoroutine calls t.Parallel through parallel in a goroutine"
+	}()
+	<-done
+}
+
+func TestParallelInSubtestLoop(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"a", "b"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+		})
+	}
+}
+
+func TestConditionalParallel(t *testing.T) {
+	if os.Getenv("PARALLEL") != "" {
+		t.Parallel()
+	}
+}
+
+func TestParallelInSwitch(t *testing.T) {
+	switch os.Getenv("MODE") {
+	case "parallel":
+		t.Parallel()
+	default:
+	}
+}
+
+func TestConditionalParallelTwice(t *testing.T) {
+	if testing.Short() {
+		t.Parallel()
+	}
+	t.Parallel() // want "Function TestConditionalParallelTwice calls t.Parallel after t.Parallel, which may panic: t.Parallel can't be called more than once"
+}
+
+func TestParallelInEitherBranch(t *testing.T) {
+	if testing.Short() {
+		t.Parallel()
+	} else {
+		t.Parallel()
+	}
+}
+
+func TestParallelInEitherCase(t *testing.T) {
+	switch {
+	case testing.Short():
+		t.Parallel()
+	case testing.Verbose():
+		t.Parallel()
+	}
+}
+
+func eitherParallel(t *testing.T) {
+	if testing.Short() {
+		t.Parallel()
+	} else {
+		t.Parallel()
+	}
+}
+
+func TestEitherParallelHelper(t *testing.T) {
+	eitherParallel(t)
+}
@@ -0,0 +1,17 @@
+package recursive
+
+import "testing"
+
+func Even(t *testing.T, n int) { // want Even:"parallel"
+	if n > 0 {
+		Odd(t, n-1)
+	}
+}
+
+func Odd(t *testing.T, n int) { // want Odd:"parallel"
+	if n > 0 {
+		Even(t, n-1)
+		return
+	}
+	t.Parallel()
+}
@@ -0,0 +1,239 @@
+package recursive
+
+import (
+	"testing"
+)
+
+type node struct {
+	name     string
+	children []*node
+}
+
+func assertNode(t *testing.T, n *node) {
+	t.Parallel()
+	for _, child := range n.children {
+		t.Run(child.name, func(t *testing.T) {
+			assertNode(t, child)
+		})
+	}
+}
+
+func TestTree(t *testing.T) {
+	assertNode(t, &node{name: "root", children: []*node{{name: "leaf"}}})
+}
+
+func assertLate(t *testing.T, n *node) {
+	for _, child := range n.children {
+		t.Run(child.name, func(t *testing.T) {
+			assertLate(t, child)
+		})
+	}
+	t.Parallel()
+}
+
+func TestTreeParallelLate(t *testing.T) {
+	assertLate(t, &node{name: "root"})
+}
+
+func assertMissing(t *testing.T, n *node) {
+	for _, child := range n.children {
+		t.Run(child.name, func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run"
+			assertMissing(t, child)
+		})
+	}
+}
+
+func TestTreeMissing(t *testing.T) { // want "Function TestTreeMissing missing the call to method parallel"
+	assertMissing(t, &node{name: "root"})
+}
+
+func setEven(t *testing.T, n int) {
+	if n > 0 {
+		setOdd(t, n-1)
+	}
+}
+
+func setOdd(t *testing.T, n int) {
+	if n > 0 {
+		setEven(t, n-1)
+		return
+	}
+	t.Setenv("DEPTH", "0")
+}
+
+func TestMutualRecursion(t *testing.T) {
+	setEven(t, 4)
+}
+
+func TestExportedMutualRecursion(t *testing.T) {
+	Even(t, 4)
+}
+
+func TestSelf(t *testing.T) { // want "Function TestSelf missing the call to method parallel"
+	if false {
+		TestSelf(t)
+	}
+}
+
+// ring0 to ring21 call each other in a cycle, each calling the next one twice.
+func ring0(t *testing.T, n int) {
+	if n > 0 {
+		ring1(t, n-1)
+		ring1(t, n-1)
+	}
+}
+
+func ring1(t *testing.T, n int) {
+	if n > 0 {
+		ring2(t, n-1)
+		ring2(t, n-1)
+	}
+}
+
+func ring2(t *testing.T, n int) {
+	if n > 0 {
+		ring3(t, n-1)
+		ring3(t, n-1)
+	}
+}
+
+func ring3(t *testing.T, n int) {
+	if n > 0 {
+		ring4(t, n-1)
+		ring4(t, n-1)
+	}
+}
+
+func ring4(t *testing.T, n int) {
+	if n > 0 {
+		ring5(t, n-1)
+		ring5(t, n-1)
+	}
+}
+
+func ring5(t *testing.T, n int) {
+	if n > 0 {
+		ring6(t, n-1)
+		ring6(t, n-1)
+	}
+}
+
+func ring6(t *testing.T, n int) {
+	if n > 0 {
+		ring7(t, n-1)
+		ring7(t, n-1)
+	}
+}
+
+func ring7(t *testing.T, n int) {
+	if n > 0 {
+		ring8(t, n-1)
+		ring8(t, n-1)
+	}
+}
+
+func ring8(t *testing.T, n int) {
+	if n > 0 {
+		ring9(t, n-1)
+		ring9(t, n-1)
+	}
+}
+
+func ring9(t *testing.T, n int) {
+	if n > 0 {
+		ring10(t, n-1)
+		ring10(t, n-1)
+	}
+}
+
+func ring10(t *testing.T, n int) {
+	if n > 0 {
+		ring11(t, n-1)
+		ring11(t, n-1)
+	}
+}
+
+func ring11(t *testing.T, n int) {
+	if n > 0 {
+		ring12(t, n-1)
+		ring12(t, n-1)
+	}
+}
+
+func ring12(t *testing.T, n int) {
+	if n > 0 {
+		ring13(t, n-1)
+		ring13(t, n-1)
+	}
+}
+
+func ring13(t *testing.T, n int) {
+	if n > 0 {
+		ring14(t, n-1)
+		ring14(t, n-1)
+	}
+}
+
+func ring14(t *testing.T, n int) {
+	if n > 0 {
+		ring15(t, n-1)
+		ring15(t, n-1)
+	}
+}
+
+func ring15(t *testing.T, n int) {
+	if n > 0 {
+		ring16(t, n-1)
+		ring16(t, n-1)
+	}
+}
+
+func ring16(t *testing.T, n int) {
+	if n > 0 {
+		ring17(t, n-1)
+		ring17(t, n-1)
+	}
+}
+
+func ring17(t *testing.T, n int) {
+	if n > 0 {
+		ring18(t, n-1)
+		ring18(t, n-1)
+	}
+}
+
+func ring18(t *testing.T, n int) {
+	if n > 0 {
+		ring19(t, n-1)
+		ring19(t, n-1)
+	}
+}
+
+func ring19(t *testing.T, n int) {
+	if n > 0 {
+		ring20(t, n-1)
+		ring20(t, n-1)
+	}
+}
+
+func ring20(t *testing.T, n int) {
+	if n > 0 {
+		ring21(t, n-1)
+		ring21(t, n-1)
+	}
+}
+
+func ring21(t *testing.T, n int) {
+	if n == 0 {
+		t.Parallel()
+		return
+	}
+	if n > 0 {
+		ring0(t, n-1)
+		ring0(t, n-1)
+	}
+}
+
+func TestRing(t *testing.T) {
+	ring0(t, 3)
+}
@@ -0,0 +1,8 @@
+package helpers
+
+import "testing"
+
+// Setup shares its name with a helper of the resolve package.
+func Setup(t *testing.T) {
+	t.Helper()
+}
@@ -0,0 +1,63 @@
+package resolve
+
+import (
+	"fmt"
+	"testing"
+
+	"resolve/helpers"
+)
+
+func Setup(t *testing.T) {
+	t.Parallel()
+}
+
+func setup(t *testing.T) {
+	t.Setenv("foo", "bar")
+}
+
+type suite struct{}
+
+func (suite) setup(t *testing.T) {
+	t.Helper()
+}
+
+func (suite) parallelSetup(t *testing.T) {
+	t.Parallel()
+}
+
+func (suite) testCase(t *testing.T) {
+	fmt.Println("missing")
+}
+
+func (suite) parallelTestCase(t *testing.T) {
+	t.Parallel()
+}
+
+func TestLocalHelper(t *testing.T) {
+	Setup(t)
+}
+
+func TestQualifiedHelper(t *testing.T) { // want "Function TestQualifiedHelper missing the call to method parallel"
+	helpers.Setup(t)
+}
+
+func TestLocalSetenvHelper(t *testing.T) {
+	setup(t)
+}
+
+func TestMethodHelper(t *testing.T) { // want "Function TestMethodHelper missing the call to method parallel"
+	var s suite
+	s.setup(t)
+}
+
+func TestParallelMethodHelper(t *testing.T) {
+	var s suite
+	s.parallelSetup(t)
+}
+
+func TestMethodValueSubtests(t *testing.T) {
+	t.Parallel()
+	var s suite
+	t.Run("1", s.testCase) // want "Function testCase missing the call to method parallel in the t.Run\n"
+	t.Run("2", s.parallelTestCase)
+}
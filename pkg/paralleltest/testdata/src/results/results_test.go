@@ -0,0 +1,122 @@
+package results
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCountAfterSubtests(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var results []string
+	for _, name := range []string{"a", "b", "c"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, name)
+		})
+	}
+	if len(results) != 3 { // want "Function TestCountAfterSubtests reads results before its parallel subtests write it, they only run once TestCountAfterSubtests returns: wrap the subtests in t.Run\\(\"group\", ...\\) and read results after it"
+		t.Errorf("got %d results", len(results))
+	}
+}
+
+func TestCloseAfterSubtests(t *testing.T) {
+	t.Parallel()
+	ch := make(chan int, 2)
+	t.Run("one", func(t *testing.T) {
+		t.Parallel()
+		ch <- 1
+	})
+	t.Run("two", func(t *testing.T) {
+		t.Parallel()
+		ch <- 2
+	})
+	close(ch) // want "Function TestCloseAfterSubtests reads ch before its parallel subtests write it"
+}
+
+func TestCountInCleanup(t *testing.T) {
+	t.Parallel()
+	var count int
+	var mu sync.Mutex
+	t.Cleanup(func() {
+		if count != 2 {
+			t.Errorf("got %d", count)
+		}
+	})
+	for _, name := range []string{"a", "b"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mu.Lock()
+			count++
+			mu.Unlock()
+		})
+	}
+}
+
+func TestSerialSubtests(t *testing.T) {
+	t.Parallel()
+	var results []string
+	t.Run("serial", func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run"
+		results = append(results, "serial")
+	})
+	if len(results) != 1 {
+		t.Fatal("missing result")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	t.Run("group", func(t *testing.T) {
+		for _, name := range []string{"a", "b"} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				mu.Lock()
+				seen[name] = true
+				mu.Unlock()
+			})
+		}
+	})
+	if len(seen) != 2 {
+		t.Fatal("missing results")
+	}
+}
+
+func TestRunResult(t *testing.T) {
+	t.Parallel()
+	if !t.Run("setup", func(t *testing.T) { // want "Function TestRunResult uses the result of t.Run, which is true as soon as the parallel subtest setup calls t.Parallel, before the subtest is done"
+		t.Parallel()
+	}) {
+		t.FailNow()
+	}
+	ok := t.Run("check", check) // want "Function TestRunResult uses the result of t.Run, which is true as soon as the parallel subtest check calls t.Parallel"
+	_ = ok
+}
+
+func check(t *testing.T) {
+	t.Parallel()
+}
+
+func TestSerialRunResult(t *testing.T) {
+	if !t.Run("setup", func(t *testing.T) {
+		t.Setenv("KEY", "value")
+	}) {
+		t.FailNow()
+	}
+	t.Run("check", check)
+}
+
+func TestWriteBeforeParallel(t *testing.T) {
+	t.Parallel()
+	ran := 0
+	t.Run("a", func(t *testing.T) {
+		ran++
+		t.Parallel()
+	})
+	if ran != 1 {
+		t.Fatal("subtest didn't run")
+	}
+}
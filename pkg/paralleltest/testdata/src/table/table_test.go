@@ -0,0 +1,143 @@
+package table
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLocalFunctionVariable(t *testing.T) {
+	t.Parallel()
+	run := func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+		fmt.Println("run")
+	}
+	t.Run("1", run)
+
+	var parallelRun = func(t *testing.T) {
+		t.Parallel()
+	}
+	t.Run("2", parallelRun)
+}
+
+func TestReassignedFunctionVariable(t *testing.T) {
+	t.Parallel()
+	run := func(t *testing.T) {
+		t.Parallel()
+	}
+	if testing.Short() {
+		run = func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+			fmt.Println("short")
+		}
+	}
+	t.Run("1", run)
+}
+
+func TestFunctionVariableWithSetenv(t *testing.T) {
+	run := func(t *testing.T) {
+		t.Setenv("foo", "bar")
+	}
+	t.Run("1", run)
+}
+
+func TestTableFunctionField(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		test func(t *testing.T)
+	}{
+		{
+			name: "parallel",
+			test: func(t *testing.T) {
+				t.Parallel()
+			},
+		},
+		{
+			name: "missing",
+			test: func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+				fmt.Println("missing")
+			},
+		},
+		{
+			name: "named",
+			test: namedCase, // want "Function namedCase missing the call to method parallel in the t.Run\n"
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, tc.test)
+	}
+}
+
+func namedCase(t *testing.T) {
+	fmt.Println("named")
+}
+
+type testCase struct {
+	name string
+	fn   func(*testing.T)
+}
+
+var packageCases = []testCase{
+	{"positional", func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+		fmt.Println("positional")
+	}},
+	{name: "keyed", fn: func(t *testing.T) {
+		t.Parallel()
+	}},
+}
+
+func TestPackageLevelTable(t *testing.T) {
+	t.Parallel()
+	for _, tc := range packageCases {
+		t.Run(tc.name, tc.fn)
+	}
+}
+
+func TestPointerTable(t *testing.T) {
+	t.Parallel()
+	cases := []*testCase{
+		{name: "pointer", fn: func(t *testing.T) {
+			t.Parallel()
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, tc.fn)
+	}
+}
+
+func TestMapOfFuncs(t *testing.T) {
+	t.Parallel()
+	for name, test := range map[string]func(*testing.T){
+		"parallel": func(t *testing.T) {
+			t.Parallel()
+		},
+		"missing": func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+			fmt.Println("missing")
+		},
+	} {
+		t.Run(name, test)
+	}
+}
+
+func TestSliceOfFuncs(t *testing.T) {
+	t.Parallel()
+	tests := []func(*testing.T){
+		func(t *testing.T) {
+			t.Parallel()
+		},
+		namedCase, // want "Function namedCase missing the call to method parallel in the t.Run\n"
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), test)
+	}
+}
+
+func TestIndexedFuncs(t *testing.T) {
+	t.Parallel()
+	tests := map[string]func(*testing.T){
+		"missing": func(t *testing.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+			fmt.Println("missing")
+		},
+	}
+	for name := range tests {
+		t.Run(name, tests[name])
+	}
+}
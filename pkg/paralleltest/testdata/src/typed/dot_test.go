@@ -0,0 +1,14 @@
+package typed
+
+import . "testing"
+
+func TestDotImport(t *T) { // want "Function TestDotImport missing the call to method parallel"
+}
+
+func TestDotImportWithParallel(t *T) {
+	t.Parallel()
+}
+
+func TestDotImportWithSetenv(t *T) {
+	t.Setenv("foo", "bar")
+}
@@ -0,0 +1,45 @@
+package typed
+
+import (
+	"fmt"
+	tt "testing"
+)
+
+func TestAliasedImport(t *tt.T) { // want "Function TestAliasedImport missing the call to method parallel"
+	fmt.Println("aliased")
+}
+
+func TestAliasedImportWithParallel(t *tt.T) {
+	t.Parallel()
+}
+
+func TestMethodExpression(t *tt.T) {
+	(*tt.T).Parallel(t)
+	(*tt.T).Run(t, "1", func(t *tt.T) { // want "Function literal missing the call to method parallel in the t.Run\n"
+		fmt.Println("1")
+	})
+	(*tt.T).Run(t, "2", func(t *tt.T) {
+		(*tt.T).Parallel(t)
+	})
+}
+
+func setenvHelper(tb tt.TB) {
+	tb.Setenv("foo", "bar")
+}
+
+func TestTBHelper(t *tt.T) {
+	setenvHelper(t)
+}
+
+func chdirHelper(tb tt.TB) {
+	tb.Chdir("foo")
+}
+
+func TestTBHelperChdir(t *tt.T) {
+	chdirHelper(t)
+}
+
+func TestOtherTestingVar(t *tt.T) { // want "Function TestOtherTestingVar missing the call to method parallel"
+	var other *tt.T
+	other.Parallel()
+}
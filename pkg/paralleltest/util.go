@@ -1,134 +1,260 @@
 package paralleltest
 
 import (
-	"bytes"
-	//nolint:gosec // G401: Weak cryptographic primitive
-	"crypto/md5"
-	"encoding/hex"
 	"go/ast"
-	"go/printer"
+	"go/constant"
 	"go/token"
-	"strconv"
+	"go/types"
+	"path/filepath"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
 )
 
-// goFmt returns the position plus the string representation of an AST subtree.
-func goFmt(node ast.Node) string {
-	buf := bytes.Buffer{}
-	fs := token.NewFileSet()
-	buf.WriteString(strconv.Itoa(int(node.Pos())))
-	gofmtConfig := &printer.Config{Tabwidth: 8}
-	_ = gofmtConfig.Fprint(&buf, fs, node)
-	return buf.String()
-}
+// isTestFunction checks if a function declaration is a test function, using
+// the same rules go test uses to discover tests. A test function must:
+// 1. Be named "Test", or start with "Test" followed by a character that is not lowercase
+// 2. Be a package-level function without a receiver or type parameters
+// 3. Have exactly one parameter, of type *testing.T, and no results
+// Returns true if it is a test function, otherwise false.
+func isTestFunction(pass *analysis.Pass, funcDecl *ast.FuncDecl) bool {
+	testPrefix := "Test"
 
-// nodeHash yields the MD5 hash of the given AST node.
-func nodeHash(node ast.Node) string {
-	hasher := func(in string) string {
-		//nolint:gosec // G401: Weak cryptographic primitive
-		binHash := md5.Sum([]byte(in))
-		return hex.EncodeToString(binHash[:])
+	if !isTestName(funcDecl.Name.Name, testPrefix) {
+		return false
 	}
-	str := goFmt(node)
-	return hasher(str)
-}
 
-// isTestFunction checks if a function declaration is a test function
-// A test function must:
-// 1. Start with "Test"
-// 2. Have exactly one parameter
-// 3. Have that parameter be of type *testing.T
-// Returns true if it is a test function, otherwise false.
-func isTestFunction(funcDecl *ast.FuncDecl) bool {
-	testPrefix := "Test"
+	if funcDecl.Recv != nil || funcDecl.Type.TypeParams.NumFields() > 0 || funcDecl.Type.Results.NumFields() > 0 {
+		return false
+	}
 
-	if !strings.HasPrefix(funcDecl.Name.Name, testPrefix) {
+	if !hasExactlyOneParameter(funcDecl) || len(funcDecl.Type.Params.List[0].Names) > 1 {
 		return false
 	}
 
-	if !hasExactlyOneParameter(funcDecl) {
+	return isTestingT(pass.TypesInfo.TypeOf(funcDecl.Type.Params.List[0].Type))
+}
+
+// isTestName reports whether name is prefix, or prefix followed by a
+// character that is not lowercase, as in cmd/go.
+func isTestName(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
 		return false
 	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
 
-	return findTestParamName(funcDecl.Type.Params) != ""
+// isTestFile reports whether go test picks up the file: its name must end in
+// _test.go and, like every Go file, not start with "_" or ".".
+func isTestFile(filename string) bool {
+	base := filepath.Base(filename)
+	return strings.HasSuffix(base, "_test.go") && !strings.HasPrefix(base, "_") && !strings.HasPrefix(base, ".")
 }
 
 func hasExactlyOneParameter(funcDecl *ast.FuncDecl) bool {
 	return funcDecl.Type.Params != nil && len(funcDecl.Type.Params.List) == 1
 }
 
-// getCallName returns the name of the function called in a call expression.
-func getCallName(callExpr *ast.CallExpr) string {
-	switch fun := callExpr.Fun.(type) {
+// calleeFunc returns the function or method called in a call expression, or
+// nil for calls through function values, builtins and conversions. Calls to
+// generic functions, with explicit or inferred type arguments, resolve to the
+// generic function.
+func calleeFunc(pass *analysis.Pass, callExpr *ast.CallExpr) *types.Func {
+	fn, _ := typeutil.Callee(pass.TypesInfo, callExpr).(*types.Func)
+	return fn
+}
+
+// referencedFunc returns the function or method that an identifier, a
+// qualified identifier or a method value refers to, including explicit
+// instantiations of generic functions such as subtest[int].
+func referencedFunc(pass *analysis.Pass, expr ast.Expr) *types.Func {
+	var fn *types.Func
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.IndexExpr:
+		return referencedFunc(pass, e.X)
+	case *ast.IndexListExpr:
+		return referencedFunc(pass, e.X)
 	case *ast.Ident:
-		return fun.Name
+		fn, _ = pass.TypesInfo.Uses[e].(*types.Func)
 	case *ast.SelectorExpr:
-		return fun.Sel.Name
-	default:
-		return ""
+		fn, _ = pass.TypesInfo.Uses[e.Sel].(*types.Func)
 	}
+	return fn
 }
 
-// findFunction looks for the function declaration across all input files by name.
-// This is slightly incomplete, as we don't handle methods.
-func findFunction(pass *analysis.Pass, name string) *ast.FuncDecl {
-	if name == "" {
+// findFunction looks up the declaration of the given function or method in
+// the index of the package. Functions of other packages are not found.
+func (a *parallelAnalyzer) findFunction(fn *types.Func) *ast.FuncDecl {
+	if fn == nil {
 		return nil
 	}
-	for _, file := range pass.Files {
-		for _, decl := range file.Decls {
-			if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Name.Name == name {
-				return funcDecl
+	return a.decls[fn.Origin()]
+}
+
+// findTestParam returns the first named parameter of type *testing.T, or of a
+// type implementing testing.TB. This is for analyzing test helper functions.
+// The parameter is matched by type, so aliased and dot imports of testing work.
+func findTestParam(pass *analysis.Pass, params *ast.FieldList) types.Object {
+	for _, param := range params.List {
+		if !isTestingTB(pass, pass.TypesInfo.TypeOf(param.Type)) {
+			continue
+		}
+		for _, name := range param.Names {
+			if name.Name != "_" {
+				return pass.TypesInfo.Defs[name]
 			}
 		}
 	}
 	return nil
 }
 
-// findTestParamName returns the first parameter of type *testing.T.
-// This is for analyzing test helper functions.
-func findTestParamName(params *ast.FieldList) string {
-	for i := range params.List {
-		param := params.List[i]
-		if starExp, ok := param.Type.(*ast.StarExpr); ok {
-			if selectExpr, ok := starExp.X.(*ast.SelectorExpr); ok {
-				if selectExpr.Sel.Name == testMethodStruct {
-					if s, ok := selectExpr.X.(*ast.Ident); ok {
-						if s.Name == testMethodPackageType && len(param.Names) > 0 {
-							return param.Names[0].Name
-						}
-					}
-				}
+// isTestingT reports whether typ is *testing.T.
+func isTestingT(typ types.Type) bool {
+	ptr, ok := types.Unalias(typ).(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := types.Unalias(ptr.Elem()).(*types.Named)
+	return ok && isTestingObject(named.Obj()) && named.Obj().Name() == testMethodStruct
+}
+
+// isTestingTB reports whether typ is *testing.T or implements testing.TB.
+func isTestingTB(pass *analysis.Pass, typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	if isTestingT(typ) {
+		return true
+	}
+	tb := lookupTestingTB(pass.Pkg)
+	return tb != nil && types.Implements(typ, tb)
+}
+
+// lookupTestingTB returns the testing.TB interface when the package imports testing.
+func lookupTestingTB(pkg *types.Package) *types.Interface {
+	for _, imp := range pkg.Imports() {
+		if imp.Path() != testMethodPackageType {
+			continue
+		}
+		if obj := imp.Scope().Lookup("TB"); obj != nil {
+			if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+				return iface
 			}
 		}
 	}
-	return ""
+	return nil
 }
 
-func isParallelCall(node *ast.CallExpr, testVar string) bool {
-	return exprCallHasMethod(node, testVar, "Parallel")
+// isTestingObject reports whether the object is declared in the testing package.
+func isTestingObject(obj types.Object) bool {
+	return obj != nil && obj.Pkg() != nil && obj.Pkg().Path() == testMethodPackageType
 }
 
-func isTestRunCall(node *ast.CallExpr, testVar string) bool {
-	return exprCallHasMethod(node, testVar, "Run")
+func isParallelCall(pass *analysis.Pass, node *ast.CallExpr, testVar types.Object) bool {
+	return exprCallHasMethod(pass, node, testVar, "Parallel")
 }
 
-func isSetenvCall(node *ast.CallExpr, testVar string) bool {
-	return exprCallHasMethod(node, testVar, "Setenv")
+func isTestRunCall(pass *analysis.Pass, node *ast.CallExpr, testVar types.Object) bool {
+	return exprCallHasMethod(pass, node, testVar, "Run")
 }
 
-func isChdirCall(node *ast.CallExpr, testVar string) bool {
-	return exprCallHasMethod(node, testVar, "Chdir")
+func isSetenvCall(pass *analysis.Pass, node *ast.CallExpr, testVar types.Object) bool {
+	return exprCallHasMethod(pass, node, testVar, "Setenv")
 }
 
-func exprCallHasMethod(callExpr *ast.CallExpr, receiverName, methodName string) bool {
-	// nolint: gocritic
-	if fun, ok := callExpr.Fun.(*ast.SelectorExpr); ok {
-		if receiver, ok := fun.X.(*ast.Ident); ok {
-			return receiver.Name == receiverName && fun.Sel.Name == methodName
+func isChdirCall(pass *analysis.Pass, node *ast.CallExpr, testVar types.Object) bool {
+	return exprCallHasMethod(pass, node, testVar, "Chdir")
+}
+
+// exprCallHasMethod reports whether the call invokes the given method of the
+// testing package on testVar. Calls are resolved through the type checker, so
+// lookalike methods on other types are ignored and method expressions such as
+// (*testing.T).Parallel(t) are recognized.
+func exprCallHasMethod(pass *analysis.Pass, callExpr *ast.CallExpr, testVar types.Object, methodName string) bool {
+	if testVar == nil {
+		return false
+	}
+	fn, ok := typeutil.Callee(pass.TypesInfo, callExpr).(*types.Func)
+	if !ok || fn.Name() != methodName || !isTestingObject(fn) || fn.Signature().Recv() == nil {
+		return false
+	}
+	receiver := methodReceiver(pass, callExpr)
+	if receiver == nil {
+		return false
+	}
+	ident, ok := ast.Unparen(receiver).(*ast.Ident)
+	return ok && pass.TypesInfo.Uses[ident] == testVar
+}
+
+// methodReceiver returns the receiver expression of a method call, which is
+// the first argument of a method expression.
+func methodReceiver(pass *analysis.Pass, callExpr *ast.CallExpr) ast.Expr {
+	fun, ok := ast.Unparen(callExpr.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	selection, ok := pass.TypesInfo.Selections[fun]
+	if !ok {
+		return nil
+	}
+	switch selection.Kind() {
+	case types.MethodVal:
+		return fun.X
+	case types.MethodExpr:
+		if len(callExpr.Args) > 0 {
+			return callExpr.Args[0]
 		}
 	}
-	return false
+	return nil
+}
+
+// methodArgs returns the arguments of a method call, leaving out the receiver
+// that a method expression such as (*testing.T).Run(t, name, f) passes first.
+func methodArgs(pass *analysis.Pass, callExpr *ast.CallExpr) []ast.Expr {
+	if fun, ok := ast.Unparen(callExpr.Fun).(*ast.SelectorExpr); ok {
+		if selection, ok := pass.TypesInfo.Selections[fun]; ok && selection.Kind() == types.MethodExpr && len(callExpr.Args) > 0 {
+			return callExpr.Args[1:]
+		}
+	}
+	return callExpr.Args
+}
+
+// quietPass returns a copy of the pass that discards its diagnostics.
+func quietPass(pass *analysis.Pass) *analysis.Pass {
+	quiet := *pass
+	quiet.Report = func(analysis.Diagnostic) {}
+	return &quiet
+}
+
+// reportOnce returns a copy of the pass that drops repeated diagnostics.
+func reportOnce(pass *analysis.Pass) *analysis.Pass {
+	type key struct {
+		pos     token.Pos
+		message string
+	}
+	reported := make(map[key]bool)
+	once := *pass
+	once.Report = func(d analysis.Diagnostic) {
+		if k := (key{d.Pos, d.Message}); !reported[k] {
+			reported[k] = true
+			pass.Report(d)
+		}
+	}
+	return &once
+}
+
+// subtestName describes the name argument of a t.Run call: the constant name,
+// or the expression between angle brackets.
+func subtestName(pass *analysis.Pass, name ast.Expr) string {
+	if tv, ok := pass.TypesInfo.Types[name]; ok && tv.Value != nil && tv.Value.Kind() == constant.String {
+		return constant.StringVal(tv.Value)
+	}
+	return "<" + types.ExprString(name) + ">"
 }
@@ -0,0 +1,474 @@
+// Package resultcache caches the diagnostics and facts of an analyzer on disk,
+// so that the analyzer can skip the packages that did not change since the last
+// run and replay their diagnostics instead. The driver still loads and type
+// checks the packages, and runs the analyzers required by the cached one.
+package resultcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+// Wrap returns a copy of the analyzer that caches its results in a directory.
+// The copy has the flags of the analyzer, plus:
+//
+//	-cachedir   the directory of the cache, the cache is off if empty
+//	-clearcache remove the cached results before the run
+//	-nocache    bypass the cache, neither reading nor writing it
+//
+// The results of a package are keyed by the version, the flags of the
+// analyzer, the salt, the content of the files of the package, and the types
+// and facts of its dependencies. The version is only computed when the cache
+// is used. The salt holds the configuration that isn't set with flags.
+// Analyzers with a result type are not cached.
+func Wrap(analyzer *analysis.Analyzer, version func() string, salt, cacheDir string) *analysis.Analyzer {
+	c := &cache{analyzer: analyzer, version: sync.OnceValue(version), salt: salt, dir: cacheDir}
+
+	wrapped := *analyzer
+	wrapped.Flags = flag.FlagSet{}
+	analyzer.Flags.VisitAll(func(f *flag.Flag) {
+		wrapped.Flags.Var(f.Value, f.Name, f.Usage)
+	})
+	wrapped.Flags.StringVar(&c.dir, "cachedir", cacheDir, "cache the results of unchanged packages in the directory")
+	wrapped.Flags.BoolVar(&c.clear, "clearcache", false, "remove the cached results before the run")
+	wrapped.Flags.BoolVar(&c.bypass, "nocache", false, "bypass the cache of results")
+	if analyzer.ResultType == nil {
+		wrapped.Run = c.run
+	}
+
+	return &wrapped
+}
+
+// cache holds the options of a wrapped analyzer.
+type cache struct {
+	analyzer *analysis.Analyzer
+	version  func() string
+	salt     string
+	dir      string
+	clear    bool
+	bypass   bool
+
+	clearOnce sync.Once
+	clearErr  error
+
+	// fingerprints holds the fingerprint of the types of each package.
+	fingerprints sync.Map
+}
+
+// entry is the cached result of an analyzer on a package.
+type entry struct {
+	Diagnostics []diagnostic `json:"diagnostics"`
+	Facts       []fact       `json:"facts"`
+}
+
+// position is a token.Pos stored as an offset in a file of the package.
+type position struct {
+	File   string `json:"file"`
+	Offset int    `json:"offset"`
+}
+
+type diagnostic struct {
+	Pos            position       `json:"pos"`
+	End            *position      `json:"end,omitempty"`
+	Category       string         `json:"category,omitempty"`
+	Message        string         `json:"message"`
+	URL            string         `json:"url,omitempty"`
+	SuggestedFixes []suggestedFix `json:"suggestedFixes,omitempty"`
+	Related        []relatedInfo  `json:"related,omitempty"`
+}
+
+type suggestedFix struct {
+	Message   string     `json:"message"`
+	TextEdits []textEdit `json:"textEdits"`
+}
+
+type textEdit struct {
+	Pos     position  `json:"pos"`
+	End     *position `json:"end,omitempty"`
+	NewText []byte    `json:"newText"`
+}
+
+type relatedInfo struct {
+	Pos     position  `json:"pos"`
+	End     *position `json:"end,omitempty"`
+	Message string    `json:"message"`
+}
+
+// fact is an exported fact, gob encoded the way the driver encodes it.
+type fact struct {
+	// Object is the object path of the object of the fact, or empty for a package fact.
+	Object string `json:"object,omitempty"`
+	// Type is the index of the type of the fact in the FactTypes of the analyzer.
+	Type int    `json:"type"`
+	Data []byte `json:"data"`
+}
+
+func (c *cache) run(pass *analysis.Pass) (any, error) {
+	if c.dir == "" || c.bypass {
+		return c.analyzer.Run(pass)
+	}
+	if c.clear {
+		c.clearOnce.Do(func() { c.clearErr = os.RemoveAll(c.dir) })
+		if c.clearErr != nil {
+			return nil, fmt.Errorf("clearing the cache: %w", c.clearErr)
+		}
+	}
+
+	key, err := c.key(pass)
+	if err != nil {
+		// The package can't be cached, for example if a file can't be read.
+		return c.analyzer.Run(pass)
+	}
+	path := filepath.Join(c.dir, key[:2], key)
+	if data, err := os.ReadFile(path); err == nil {
+		var e entry
+		if err := json.Unmarshal(data, &e); err == nil && c.replay(pass, &e) == nil {
+			return nil, nil
+		}
+	}
+
+	e := &entry{}
+	cacheable := true
+	recording := *pass
+	recording.Report = func(d analysis.Diagnostic) {
+		if stored, ok := storeDiagnostic(pass.Fset, d); ok {
+			e.Diagnostics = append(e.Diagnostics, stored)
+		} else {
+			cacheable = false
+		}
+		pass.Report(d)
+	}
+	recording.ExportObjectFact = func(obj types.Object, f analysis.Fact) {
+		pass.ExportObjectFact(obj, f)
+		if stored, err := c.storeFact(obj, f); err == nil {
+			e.Facts = append(e.Facts, stored)
+		} else {
+			cacheable = false
+		}
+	}
+	recording.ExportPackageFact = func(f analysis.Fact) {
+		pass.ExportPackageFact(f)
+		if stored, err := c.storeFact(nil, f); err == nil {
+			e.Facts = append(e.Facts, stored)
+		} else {
+			cacheable = false
+		}
+	}
+
+	result, err := c.analyzer.Run(&recording)
+	if err == nil && cacheable {
+		// The cache is best effort, a result that can't be written is analyzed again next time.
+		_ = write(path, e)
+	}
+	return result, err
+}
+
+// key returns the key of the results of the analyzer on the package.
+func (c *cache) key(pass *analysis.Pass) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %q\nsalt %q\nanalyzer %q\npackage %q\n", c.version(), c.salt, c.analyzer.Name, pass.Pkg.Path())
+	c.analyzer.Flags.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(h, "flag %q %q\n", f.Name, f.Value.String())
+	})
+
+	for _, file := range pass.Files {
+		name := pass.Fset.File(file.Pos()).Name()
+		content, err := pass.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(h, "file %q %x\n", name, sum)
+	}
+
+	// The types and the facts of the dependencies are all this package sees of
+	// their code.
+	for _, dep := range dependencies(pass.Pkg) {
+		fmt.Fprintf(h, "dependency %q %s\n", dep.Path(), c.fingerprint(dep))
+	}
+	var facts []string
+	for _, f := range pass.AllObjectFacts() {
+		if f.Object.Pkg() == pass.Pkg {
+			continue
+		}
+		path, err := objectpath.For(f.Object)
+		if err != nil {
+			return "", err
+		}
+		data, err := encodeFact(f.Fact)
+		if err != nil {
+			return "", err
+		}
+		facts = append(facts, fmt.Sprintf("fact %q %q %x\n", f.Object.Pkg().Path(), path, data))
+	}
+	for _, f := range pass.AllPackageFacts() {
+		if f.Package == pass.Pkg {
+			continue
+		}
+		data, err := encodeFact(f.Fact)
+		if err != nil {
+			return "", err
+		}
+		facts = append(facts, fmt.Sprintf("fact %q %x\n", f.Package.Path(), data))
+	}
+	slices.Sort(facts)
+	for _, f := range facts {
+		h.Write([]byte(f))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dependencies returns the packages the package imports, directly or not,
+// sorted by path.
+func dependencies(pkg *types.Package) []*types.Package {
+	seen := make(map[*types.Package]bool)
+	var deps []*types.Package
+	var visit func(*types.Package)
+	visit = func(pkg *types.Package) {
+		for _, imp := range pkg.Imports() {
+			if !seen[imp] {
+				seen[imp] = true
+				deps = append(deps, imp)
+				visit(imp)
+			}
+		}
+	}
+	visit(pkg)
+	slices.SortFunc(deps, func(a, b *types.Package) int {
+		return strings.Compare(a.Path(), b.Path())
+	})
+	return deps
+}
+
+// fingerprint hashes the declarations of the package level objects of a
+// package and the methods of its types, so that it changes with any change to
+// the types of the package. Packages are shared by the passes of a run, so
+// the fingerprint of each one is only computed once.
+func (c *cache) fingerprint(pkg *types.Package) string {
+	if fp, ok := c.fingerprints.Load(pkg); ok {
+		return fp.(string)
+	}
+	qualifier := func(other *types.Package) string { return other.Path() }
+	h := sha256.New()
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		fmt.Fprintf(h, "%s\n", types.ObjectString(obj, qualifier))
+		switch obj := obj.(type) {
+		case *types.Const:
+			fmt.Fprintf(h, "= %s\n", obj.Val().ExactString())
+		case *types.TypeName:
+			if named, ok := obj.Type().(*types.Named); ok && !obj.IsAlias() {
+				for method := range named.Methods() {
+					fmt.Fprintf(h, "%s\n", types.ObjectString(method, qualifier))
+				}
+			}
+		}
+	}
+	fp, _ := c.fingerprints.LoadOrStore(pkg, hex.EncodeToString(h.Sum(nil)))
+	return fp.(string)
+}
+
+// replay reports the cached diagnostics and exports the cached facts.
+func (c *cache) replay(pass *analysis.Pass, e *entry) error {
+	files := make(map[string]*token.File)
+	for _, file := range pass.Files {
+		tokFile := pass.Fset.File(file.Pos())
+		files[tokFile.Name()] = tokFile
+	}
+
+	// Check everything first, so that nothing is replayed from a broken entry.
+	diagnostics := make([]analysis.Diagnostic, 0, len(e.Diagnostics))
+	for _, stored := range e.Diagnostics {
+		d, err := loadDiagnostic(files, stored)
+		if err != nil {
+			return err
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	type objectFact struct {
+		obj  types.Object
+		fact analysis.Fact
+	}
+	facts := make([]objectFact, 0, len(e.Facts))
+	for _, stored := range e.Facts {
+		if stored.Type < 0 || stored.Type >= len(c.analyzer.FactTypes) {
+			return fmt.Errorf("unknown fact type %d", stored.Type)
+		}
+		f := reflect.New(reflect.TypeOf(c.analyzer.FactTypes[stored.Type]).Elem()).Interface().(analysis.Fact)
+		if err := gob.NewDecoder(bytes.NewReader(stored.Data)).Decode(f); err != nil {
+			return err
+		}
+		var obj types.Object
+		if stored.Object != "" {
+			var err error
+			if obj, err = objectpath.Object(pass.Pkg, objectpath.Path(stored.Object)); err != nil {
+				return err
+			}
+		}
+		facts = append(facts, objectFact{obj, f})
+	}
+
+	for _, d := range diagnostics {
+		pass.Report(d)
+	}
+	for _, f := range facts {
+		if f.obj == nil {
+			pass.ExportPackageFact(f.fact)
+		} else {
+			pass.ExportObjectFact(f.obj, f.fact)
+		}
+	}
+	return nil
+}
+
+// storeFact encodes a fact the package exports.
+func (c *cache) storeFact(obj types.Object, f analysis.Fact) (fact, error) {
+	index := slices.IndexFunc(c.analyzer.FactTypes, func(t analysis.Fact) bool {
+		return reflect.TypeOf(t) == reflect.TypeOf(f)
+	})
+	if index < 0 {
+		return fact{}, fmt.Errorf("unknown fact type %T", f)
+	}
+	data, err := encodeFact(f)
+	if err != nil {
+		return fact{}, err
+	}
+	stored := fact{Type: index, Data: data}
+	if obj != nil {
+		path, err := objectpath.For(obj)
+		if err != nil {
+			return fact{}, err
+		}
+		stored.Object = string(path)
+	}
+	return stored, nil
+}
+
+func encodeFact(f analysis.Fact) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// storeDiagnostic converts the positions of the diagnostic to offsets. It
+// fails if a position is outside of the files of the package.
+func storeDiagnostic(fset *token.FileSet, d analysis.Diagnostic) (diagnostic, bool) {
+	ok := true
+	pos := func(p token.Pos) position {
+		tokFile := fset.File(p)
+		if tokFile == nil {
+			ok = false
+			return position{}
+		}
+		return position{File: tokFile.Name(), Offset: tokFile.Offset(p)}
+	}
+	end := func(p token.Pos) *position {
+		if !p.IsValid() {
+			return nil
+		}
+		stored := pos(p)
+		return &stored
+	}
+
+	stored := diagnostic{
+		Pos:      pos(d.Pos),
+		End:      end(d.End),
+		Category: d.Category,
+		Message:  d.Message,
+		URL:      d.URL,
+	}
+	for _, fix := range d.SuggestedFixes {
+		storedFix := suggestedFix{Message: fix.Message}
+		for _, edit := range fix.TextEdits {
+			storedFix.TextEdits = append(storedFix.TextEdits, textEdit{Pos: pos(edit.Pos), End: end(edit.End), NewText: edit.NewText})
+		}
+		stored.SuggestedFixes = append(stored.SuggestedFixes, storedFix)
+	}
+	for _, related := range d.Related {
+		stored.Related = append(stored.Related, relatedInfo{Pos: pos(related.Pos), End: end(related.End), Message: related.Message})
+	}
+	return stored, ok
+}
+
+// loadDiagnostic converts the offsets of a cached diagnostic back to positions.
+func loadDiagnostic(files map[string]*token.File, stored diagnostic) (analysis.Diagnostic, error) {
+	var err error
+	pos := func(p position) token.Pos {
+		tokFile, ok := files[p.File]
+		if !ok || p.Offset > tokFile.Size() {
+			err = fmt.Errorf("position %s:%d is outside of the package", p.File, p.Offset)
+			return token.NoPos
+		}
+		return tokFile.Pos(p.Offset)
+	}
+	end := func(p *position) token.Pos {
+		if p == nil {
+			return token.NoPos
+		}
+		return pos(*p)
+	}
+
+	d := analysis.Diagnostic{
+		Pos:      pos(stored.Pos),
+		End:      end(stored.End),
+		Category: stored.Category,
+		Message:  stored.Message,
+		URL:      stored.URL,
+	}
+	for _, fix := range stored.SuggestedFixes {
+		loadedFix := analysis.SuggestedFix{Message: fix.Message}
+		for _, edit := range fix.TextEdits {
+			loadedFix.TextEdits = append(loadedFix.TextEdits, analysis.TextEdit{Pos: pos(edit.Pos), End: end(edit.End), NewText: edit.NewText})
+		}
+		d.SuggestedFixes = append(d.SuggestedFixes, loadedFix)
+	}
+	for _, related := range stored.Related {
+		d.Related = append(d.Related, analysis.RelatedInformation{Pos: pos(related.Pos), End: end(related.End), Message: related.Message})
+	}
+	return d, err
+}
+
+// write stores the entry at path, through a temporary file so that concurrent
+// runs never read a partial entry.
+func write(path string, e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,130 @@
+package resultcache
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/kunwardeep/paralleltest/pkg/paralleltest"
+)
+
+// countingAnalyzer returns the paralleltest analyzer and the number of
+// packages it analyzed.
+func countingAnalyzer() (*analysis.Analyzer, *atomic.Int32) {
+	analyzer := paralleltest.NewAnalyzer(paralleltest.Config{})
+	run := analyzer.Run
+	var runs atomic.Int32
+	analyzer.Run = func(pass *analysis.Pass) (any, error) {
+		runs.Add(1)
+		return run(pass)
+	}
+	return analyzer, &runs
+}
+
+func testVersion() string {
+	return "test"
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	run := func(t *testing.T, salt string, flags ...string) int32 {
+		t.Helper()
+		analyzer, runs := countingAnalyzer()
+		cached := Wrap(analyzer, testVersion, salt, dir)
+		for i := 0; i+1 < len(flags); i += 2 {
+			if err := cached.Flags.Set(flags[i], flags[i+1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		analysistest.Run(t, analysistest.TestData(), cached, "a")
+		return runs.Load()
+	}
+
+	if runs := run(t, ""); runs == 0 {
+		t.Fatal("the first run analyzed no package")
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) == 0 {
+		t.Fatalf("the first run cached nothing: %v", err)
+	}
+
+	// The diagnostics and facts are replayed from the cache.
+	if runs := run(t, ""); runs != 0 {
+		t.Errorf("the cached run analyzed %d packages, want 0", runs)
+	}
+	if runs := run(t, "", "i", "false"); runs != 0 {
+		t.Errorf("the run with an unchanged flag analyzed %d packages, want 0", runs)
+	}
+
+	if runs := run(t, "other config"); runs == 0 {
+		t.Error("the run with another config analyzed no package")
+	}
+	if runs := run(t, "", "nocache", "true"); runs == 0 {
+		t.Error("the run bypassing the cache analyzed no package")
+	}
+	if runs := run(t, "", "clearcache", "true"); runs == 0 {
+		t.Error("the run clearing the cache analyzed no package")
+	}
+	if runs := run(t, ""); runs != 0 {
+		t.Errorf("the run after clearing the cache analyzed %d packages, want 0", runs)
+	}
+}
+
+func TestCacheDependencyTypes(t *testing.T) {
+	t.Parallel()
+
+	testdata := t.TempDir()
+	if err := os.CopyFS(testdata, os.DirFS(analysistest.TestData())); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	run := func(t *testing.T) int32 {
+		t.Helper()
+		analyzer, runs := countingAnalyzer()
+		analysistest.Run(t, testdata, Wrap(analyzer, testVersion, "", dir), "a")
+		return runs.Load()
+	}
+
+	first := run(t)
+	if first < 2 {
+		t.Fatalf("the first run analyzed %d packages, want a and its helpers", first)
+	}
+
+	// A change to the types of a dependency that doesn't change its facts
+	// still invalidates the packages that import it.
+	helpers := filepath.Join(testdata, "src", "a", "helpers", "helpers.go")
+	f, err := os.OpenFile(helpers, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("\ntype Options struct{ Level string }\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if runs := run(t); runs < 2 {
+		t.Errorf("the run after changing the types of a dependency analyzed %d packages, want a and its helpers", runs)
+	}
+}
+
+func TestCacheOff(t *testing.T) {
+	t.Parallel()
+
+	analyzer, runs := countingAnalyzer()
+	analysistest.Run(t, analysistest.TestData(), Wrap(analyzer, testVersion, "", ""), "a")
+	first := runs.Load()
+	analysistest.Run(t, analysistest.TestData(), Wrap(analyzer, testVersion, "", ""), "a")
+
+	if second := runs.Load() - first; first == 0 || second != first {
+		t.Errorf("the runs without a cache directory analyzed %d and %d packages, want the same", first, second)
+	}
+}
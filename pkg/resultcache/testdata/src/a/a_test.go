@@ -0,0 +1,15 @@
+package a
+
+import (
+	"testing"
+
+	"a/helpers"
+)
+
+func TestMissing(t *testing.T) { // want "Function TestMissing missing the call to method parallel"
+	t.Log("missing")
+}
+
+func TestSetup(t *testing.T) {
+	helpers.Setup(t)
+}
@@ -0,0 +1,7 @@
+package helpers
+
+import "testing"
+
+func Setup(t *testing.T) { // want Setup:"cantParallel: calls \\(\\*testing.T\\).Setenv"
+	t.Setenv("LEVEL", "debug")
+}